@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// partialSuffix is appended to the name of an incomplete output file kept
+// for debugging
+const partialSuffix = ".partial"
+
+// createTempBeside creates a new hidden temporary file in the directory of
+// path, so it can be renamed to path atomically
+func createTempBeside(path string) (*os.File, error) {
+	return os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+}
+
+// writeFileAtomic streams write into a temporary file beside path, syncs it
+// and renames it to path only if write succeeded, so path is either the
+// complete new file or left as it was. The file gets the permissions of the
+// file it replaces, a new one 0644. After a failure the temporary file is
+// removed, or kept as path.partial if keepPartial is set; partial is its name
+// then.
+func writeFileAtomic(path string, keepPartial bool, write func(out io.Writer) error) (partial string, err error) {
+	file, err := createTempBeside(path)
+	if err != nil {
+		return "", err
+	}
+
+	mode := fs.FileMode(0o644)
+	if info, statErr := os.Stat(path); statErr == nil {
+		mode = info.Mode().Perm()
+	}
+
+	err = write(file)
+	if err == nil {
+		err = errors.Join(file.Chmod(mode), file.Sync())
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err == nil {
+		return "", nil
+	}
+
+	if keepPartial {
+		partial = path + partialSuffix
+		if renameErr := os.Rename(file.Name(), partial); renameErr == nil {
+			return partial, err
+		}
+	}
+	os.Remove(file.Name())
+	return "", err
+}
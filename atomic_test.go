@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "export.csv")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0o600))
+
+	partial, err := writeFileAtomic(path, false, func(out io.Writer) error {
+		_, err := io.WriteString(out, "new")
+		return err
+	})
+	require.NoError(t, err)
+	assert.Empty(t, partial)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "The permissions of the replaced file should be kept")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "No temporary file should be left")
+}
+
+func TestWriteFileAtomic_Failure(t *testing.T) {
+	failing := func(out io.Writer) error {
+		io.WriteString(out, "half")
+		return errors.New("API gone")
+	}
+
+	t.Run("existing file is kept", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "export.csv")
+		require.NoError(t, os.WriteFile(path, []byte("old"), 0o600))
+
+		partial, err := writeFileAtomic(path, false, failing)
+		assert.EqualError(t, err, "API gone")
+		assert.Empty(t, partial)
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "old", string(content))
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "The temporary file should be removed")
+	})
+
+	t.Run("partial file is kept", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "export.csv")
+
+		partial, err := writeFileAtomic(path, true, failing)
+		assert.Error(t, err)
+		assert.Equal(t, path+".partial", partial)
+		assert.NoFileExists(t, path)
+
+		content, err := os.ReadFile(partial)
+		require.NoError(t, err)
+		assert.Equal(t, "half", string(content))
+	})
+}
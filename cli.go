@@ -0,0 +1,948 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/mariow/nicmanager-export/nicmanager"
+)
+
+// exit codes of the command line mode
+const (
+	exitOK        = 0
+	exitError     = 1
+	exitUsage     = 2
+	exitAuth      = 3
+	exitRateLimit = 4
+	exitAPIStatus = 5
+	exitDecode    = 6
+	exitData      = 7
+	// exitCanceled follows the shell convention of 128 + SIGINT
+	exitCanceled = 130
+)
+
+// passwordEnvVar is read when no password is given on the command line
+const passwordEnvVar = "NICMANAGER_PASSWORD"
+
+const cliUsage = `Usage: nicmanager-export [command] [flags]
+
+Without a command the graphical user interface is started.
+
+Commands:
+  export    fetch the domain inventory and write it to a file, as CSV, XLSX,
+            ODS, JSON, NDJSON or SQLite
+  diff      compare two exports, or an export with the current inventory,
+            and report added, removed and changed domains
+  snapshots list, inspect and prune the stored domain lists of past exports
+  credentials
+            store the API password in the OS keyring or an encrypted file
+  columns   list the columns available for export -columns
+  help      show this help
+
+Run "nicmanager-export <command> -h" for the flags of a command.
+
+Exit codes:
+  0    success
+  1    other error, e.g. the output file could not be written
+  2    invalid command line
+  3    authentication failed
+  4    rate limited by the API
+  5    unexpected API status code
+  6    unreadable API response
+  7    unreadable date in a record (-strict)
+  130  canceled
+`
+
+// runCLI executes a headless subcommand and returns the process exit code
+func runCLI(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, cliUsage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "export":
+		return runExportCommand(args[1:], stdout, stderr)
+	case "diff":
+		return runDiffCommand(args[1:], stdout, stderr)
+	case "snapshots":
+		return runSnapshotsCommand(args[1:], stdout, stderr)
+	case "credentials":
+		return runCredentialsCommand(args[1:], stdout, stderr)
+	case "columns":
+		printColumns(stdout)
+		return exitOK
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, cliUsage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+		fmt.Fprint(stderr, cliUsage)
+		return exitUsage
+	}
+}
+
+func runExportCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	login := fs.String("login", "", "Nicmanager API user (account.user)")
+	password := fs.String("password", "", "Nicmanager API password, visible to other users of the system (default from -password-file, -password-command, -password-env, else the credential store)")
+	passwordFlags := addPasswordFlags(fs)
+	cutoff := fs.String("cutoff", "", "inventory cutoff date (YYYY-MM-DD), the whole day counts (default today)")
+	mode := fs.String("mode", string(modeCutoff), "inventory mode: cutoff (not deleted by the cutoff date), as-of (portfolio at the cutoff date) or between (in the portfolio at any point from -from to the cutoff date)")
+	from := fs.String("from", "", "first day (YYYY-MM-DD) of the between mode")
+	timezone := fs.String("timezone", "Local", "IANA time zone of the cutoff date and the exported dates, e.g. Europe/Berlin")
+	output := fs.String("output", "", "output file, - writes to stdout; relative to the output_dir of the profile, if it has one (default Export_<cutoff>.<format> there)")
+	format := fs.String("format", "", "output format csv, xlsx, ods, json, ndjson or sqlite, must match the output file extension if that names a format (default from the output file extension, else the profile, else csv)")
+	apiURL := fs.String("api-url", nicmanager.DefaultBaseURL, "base URL of the Nicmanager API")
+	retries := fs.Int("retries", nicmanager.DefaultRetryPolicy.MaxAttempts-1, "retries of API requests failing with a transient error")
+	requestRate := fs.Float64("rate", defaultRequestRate, "maximum API requests per second, 0 disables the limit")
+	requestBurst := fs.Int("burst", defaultRequestBurst, "number of API requests allowed in a burst")
+	workers := fs.Int("workers", defaultConcurrency, "number of pages fetched in parallel")
+	columnSpec := fs.String("columns", "", "comma separated columns of CSV, XLSX and ODS output, each optionally key=Label (default "+defaultColumnSpec+", see the columns command)")
+	csvPreset := fs.String("csv-preset", "", "CSV dialect preset: "+strings.Join(csvPresetNames, ", ")+" (default default)")
+	csvDelimiter := fs.String("csv-delimiter", "", "CSV field delimiter, a single character or tab (default from the preset)")
+	csvQuote := fs.String("csv-quote", "", "CSV quoting, minimal or all (default from the preset)")
+	csvLineEnding := fs.String("csv-line-ending", "", "CSV line ending, lf or crlf (default from the preset)")
+	csvBOM := fs.Bool("csv-bom", false, "start the CSV file with a UTF-8 byte order mark (default from the preset)")
+	dateFormat := fs.String("date-format", "", "date format of the CSV output, e.g. DD.MM.YYYY (default from the preset)")
+	tlds := fs.String("tld", "", "comma separated top level domains to export, e.g. com,net")
+	names := fs.String("name", "", "comma separated glob patterns the domain name has to match, e.g. *shop*.de")
+	nameRegex := fs.String("name-regex", "", "regular expression the domain name has to match")
+	statuses := fs.String("status", "", "comma separated order statuses to export, e.g. active")
+	ordered := fs.String("ordered", "", "order date range YYYY-MM-DD..YYYY-MM-DD, either side may be empty")
+	registered := fs.String("registered", "", "registration date range YYYY-MM-DD..YYYY-MM-DD, either side may be empty")
+	deleted := fs.String("deleted", "", "delete date range YYYY-MM-DD..YYYY-MM-DD, either side may be empty")
+	snapshotID := fs.String("snapshot", "", "export a stored snapshot (an ID from the snapshots command or latest) instead of fetching from the API")
+	noSnapshot := fs.Bool("no-snapshot", false, "do not store the fetched domain list as snapshot")
+	configPath := fs.String("config", "", "config file (default nicmanager-export/config.toml in the user config directory)")
+	profile := fs.String("profile", "", "named profile of the config file (default $"+profileEnvVar+")")
+	strict := fs.Bool("strict", false, "fail if any date of a record cannot be read")
+	keepPartial := fs.Bool("keep-partial", false, "keep the output of a failed export as <output>.partial for debugging, csv, json and ndjson only")
+	debug := fs.Bool("debug", false, "write the debug log to stderr")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	// the profile fills in what is not given on the command line
+	config, configErr := loadConfig(*configPath)
+	if configErr != nil {
+		fmt.Fprintf(stderr, "export: %v\n", configErr)
+		return exitUsage
+	}
+	settings, profileErr := config.profile(*profile)
+	if profileErr != nil {
+		fmt.Fprintf(stderr, "export: %v\n", profileErr)
+		return exitUsage
+	}
+	applyProfile(fs, settings, login, timezone, mode)
+	if *password == "" && *snapshotID == "" {
+		var pwErr error
+		if *password, pwErr = lookupPassword(*login, passwordFlags.source(settings), config.Credentials, stderr); pwErr != nil {
+			fmt.Fprintf(stderr, "export: %v\n", pwErr)
+			return exitUsage
+		}
+	}
+
+	if (*output == "" && settings.OutputDir == "") || (*snapshotID == "" && (*login == "" || *password == "")) {
+		fmt.Fprintln(stderr, "export: -login, -password and -output are required, or -snapshot and -output")
+		fs.Usage()
+		return exitUsage
+	}
+
+	outFormat := formatCSV
+	pathFormat, pathHasFormat := formatFromPath(*output)
+	switch {
+	case *format != "" || (!pathHasFormat && settings.Format != ""):
+		var fmtErr error
+		if outFormat, fmtErr = parseOutputFormat(cmp.Or(*format, settings.Format)); fmtErr != nil {
+			fmt.Fprintf(stderr, "export: %v\n", fmtErr)
+			return exitUsage
+		}
+		// an extension naming another format is most likely a mistake
+		if pathHasFormat && pathFormat != outFormat {
+			fmt.Fprintf(stderr, "export: -format %s does not match the extension of %s\n", outFormat, *output)
+			return exitUsage
+		}
+	case pathHasFormat:
+		outFormat = pathFormat
+	}
+	if outFormat == formatSQLite && *output == "-" {
+		fmt.Fprintln(stderr, "export: sqlite output needs a file, it cannot be written to stdout")
+		return exitUsage
+	}
+	if *keepPartial && !outFormat.streams() {
+		fmt.Fprintf(stderr, "export: -keep-partial works with csv, json and ndjson only, not with %s\n", outFormat)
+		return exitUsage
+	}
+
+	columns, colErr := settings.columns()
+	if *columnSpec != "" {
+		columns, colErr = parseColumns(*columnSpec)
+	}
+	if colErr != nil {
+		fmt.Fprintf(stderr, "export: %v\n", colErr)
+		return exitUsage
+	}
+
+	csvFlags := csvSettings{
+		Preset:     *csvPreset,
+		Delimiter:  *csvDelimiter,
+		Quote:      *csvQuote,
+		LineEnding: *csvLineEnding,
+		DateFormat: *dateFormat,
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "csv-bom" {
+			csvFlags.BOM = csvBOM
+		}
+	})
+	dialect, dialectErr := settings.CSV.override(csvFlags).dialect()
+	if dialectErr != nil {
+		fmt.Fprintf(stderr, "export: %v\n", dialectErr)
+		return exitUsage
+	}
+
+	loc, tzErr := time.LoadLocation(*timezone)
+	if tzErr != nil {
+		fmt.Fprintf(stderr, "export: unknown time zone %q\n", *timezone)
+		return exitUsage
+	}
+
+	filter, filterErr := settings.Filter.override(filterSettings{
+		TLDs:       splitList(*tlds),
+		Names:      splitList(*names),
+		NameRegex:  *nameRegex,
+		Statuses:   splitList(*statuses),
+		Ordered:    *ordered,
+		Registered: *registered,
+		Deleted:    *deleted,
+	}).compile(loc)
+	if filterErr != nil {
+		fmt.Fprintf(stderr, "export: %v\n", filterErr)
+		return exitUsage
+	}
+
+	if *cutoff == "" {
+		*cutoff = time.Now().In(loc).Format("2006-01-02")
+	}
+	cutoffDate, dtErr := parseCutoffDate(*cutoff, loc)
+	if dtErr != nil {
+		fmt.Fprintf(stderr, "export: invalid cutoff date %q, expected YYYY-MM-DD\n", *cutoff)
+		return exitUsage
+	}
+
+	// without -output the file is named after the cutoff date, relative
+	// files are written to the output directory of the profile
+	if *output == "" {
+		*output = "Export_" + *cutoff + "." + string(outFormat)
+	}
+	if *output != "-" {
+		var pathErr error
+		if *output, pathErr = settings.outputPath(*output); pathErr != nil {
+			fmt.Fprintf(stderr, "export: %v\n", pathErr)
+			return exitUsage
+		}
+	}
+
+	inventory, modeErr := parseInventoryMode(*mode)
+	if modeErr != nil {
+		fmt.Fprintf(stderr, "export: %v\n", modeErr)
+		return exitUsage
+	}
+	var startDate time.Time
+	switch {
+	case inventory == modeBetween && *from == "":
+		fmt.Fprintln(stderr, "export: -mode between needs -from")
+		return exitUsage
+	case inventory != modeBetween && *from != "":
+		fmt.Fprintln(stderr, "export: -from is only used with -mode between")
+		return exitUsage
+	case *from != "":
+		var fromErr error
+		if startDate, fromErr = parseStartDate(*from, loc); fromErr != nil {
+			fmt.Fprintf(stderr, "export: invalid -from date %q, expected YYYY-MM-DD\n", *from)
+			return exitUsage
+		}
+		if startDate.After(cutoffDate) {
+			fmt.Fprintf(stderr, "export: -from %s is after the cutoff date %s\n", *from, *cutoff)
+			return exitUsage
+		}
+	}
+
+	store, storeErr := openSnapshotStore(config.Snapshots.Dir)
+
+	if *debug {
+		log.SetOutput(stderr)
+	} else {
+		log.SetOutput(io.Discard)
+	}
+
+	// fail before a long fetch, not after it
+	if *output != "-" {
+		if err := checkOutputPath(*output); err != nil {
+			fmt.Fprintf(stderr, "export: %v\n", err)
+			return exitError
+		}
+	}
+
+	retryPolicy := nicmanager.DefaultRetryPolicy
+	retryPolicy.MaxAttempts = *retries + 1
+
+	client := newAPIClient(*login, *password,
+		nicmanager.WithBaseURL(*apiURL),
+		nicmanager.WithRetryPolicy(retryPolicy),
+		nicmanager.WithRateLimit(*requestRate, *requestBurst),
+		nicmanager.WithConcurrency(*workers),
+	)
+
+	// Ctrl-C or a TERM from cron stops the export cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := exportOptions{
+		CutoffDate:  cutoffDate,
+		Mode:        inventory,
+		StartDate:   startDate,
+		Location:    loc,
+		Format:      outFormat,
+		Columns:     columns,
+		CSV:         dialect,
+		Filter:      filter,
+		Strict:      *strict,
+		KeepPartial: *keepPartial,
+	}
+
+	// the domains come from the API, every fetch is stored as snapshot, or
+	// from a stored snapshot
+	domains := client.Domains(ctx, nicmanager.DefaultPageSize)
+	if *snapshotID != "" {
+		if storeErr != nil {
+			fmt.Fprintf(stderr, "export: %v\n", storeErr)
+			return exitError
+		}
+		info, err := store.lookup(*snapshotID)
+		if err != nil {
+			fmt.Fprintf(stderr, "export: %v\n", err)
+			return exitUsage
+		}
+		domains = snapshotDomains(info.Path)
+	} else if !*noSnapshot && !config.Snapshots.Disabled {
+		recorder, err := newSnapshotRecorder(store, storeErr)
+		if err != nil {
+			fmt.Fprintf(stderr, "warning: no snapshot is stored: %v\n", err)
+		}
+		opts.Snapshot = recorder
+	}
+
+	var result exportResult
+	var err error
+	if *output == "-" {
+		result, err = exportDomains(domains, opts, stdout)
+	} else {
+		result, err = exportDomainsToFile(domains, opts, *output)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		if *output == "-" {
+			fmt.Fprintln(stderr, "export: canceled, the output is incomplete")
+		} else {
+			fmt.Fprintln(stderr, "export: canceled, no output file written")
+		}
+		reportPartial(stderr, result)
+		return exitCanceled
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "export: %v\n", err)
+		reportPartial(stderr, result)
+		return exitCode(err)
+	}
+
+	for _, issue := range result.Issues {
+		fmt.Fprintf(stderr, "warning: %v\n", issue)
+	}
+	fmt.Fprintf(stderr, "%d records written, %d unreadable dates\n", result.RecordsWritten, len(result.Issues))
+	reportSnapshot(stderr, store, config.Snapshots.retention(), result)
+	return exitOK
+}
+
+// applyProfile sets the login, time zone and mode flags not given on the
+// command line from the profile settings; mode may be nil
+func applyProfile(fs *flag.FlagSet, settings profileSettings, login *string, timezone *string, mode *string) {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	if !given["login"] && settings.Login != "" {
+		*login = settings.Login
+	}
+	if timezone != nil && !given["timezone"] && settings.TimeZone != "" {
+		*timezone = settings.TimeZone
+	}
+	if mode != nil && !given["mode"] && settings.Mode != "" {
+		*mode = settings.Mode
+	}
+}
+
+// passwordFlags are the flags selecting where the password is read from
+type passwordFlags struct {
+	file    *string
+	command *string
+	env     *string
+}
+
+// addPasswordFlags defines the password source flags on fs
+func addPasswordFlags(fs *flag.FlagSet) passwordFlags {
+	return passwordFlags{
+		file:    fs.String("password-file", "", "read the password from the first line of this file (default from the profile)"),
+		command: fs.String("password-command", "", "read the password from the first line printed by this shell command, e.g. of a password manager (default from the profile)"),
+		env:     fs.String("password-env", "", "read the password from this environment variable (default from the profile, else "+passwordEnvVar+")"),
+	}
+}
+
+// source returns the password source of the flags, if any of them is given
+// it replaces the one of the profile
+func (f passwordFlags) source(settings profileSettings) passwordSource {
+	if *f.file == "" && *f.command == "" && *f.env == "" {
+		return settings.passwordSource()
+	}
+	return passwordSource{File: *f.file, Command: *f.command, Env: cmp.Or(*f.env, passwordEnvVar)}
+}
+
+// lookupPassword returns the password of login from source, else from the
+// credential store, empty if neither has one. A failing credential store is
+// only a warning, a failing source an error.
+func lookupPassword(login string, source passwordSource, settings credentialSettings, stderr io.Writer) (string, error) {
+	password, ok, err := source.password(context.Background())
+	if ok || err != nil || login == "" {
+		return password, err
+	}
+
+	store, err := openCredentialStore(settings, nil)
+	if err == nil && store != nil {
+		password, err = store.lookup(login)
+	}
+	if err != nil && !errors.Is(err, errNoCredential) {
+		fmt.Fprintf(stderr, "warning: credential store: %v\n", err)
+	}
+	return password, nil
+}
+
+// reportPartial tells where the incomplete output of a failed export was
+// kept, if it was
+func reportPartial(stderr io.Writer, result exportResult) {
+	if result.PartialPath != "" {
+		fmt.Fprintf(stderr, "export: incomplete output kept in %s\n", result.PartialPath)
+	}
+}
+
+// newSnapshotRecorder starts a snapshot in store, storeErr is the error of
+// opening the store
+func newSnapshotRecorder(store *snapshotStore, storeErr error) (*snapshotRecorder, error) {
+	if storeErr != nil {
+		return nil, storeErr
+	}
+	return store.create(time.Now())
+}
+
+// reportSnapshot tells where the snapshot of an export went and applies the
+// retention policy after a new one was stored
+func reportSnapshot(stderr io.Writer, store *snapshotStore, policy retentionPolicy, result exportResult) {
+	if result.SnapshotErr != nil {
+		fmt.Fprintf(stderr, "warning: snapshot not stored: %v\n", result.SnapshotErr)
+		return
+	}
+	if result.Snapshot.ID == "" {
+		return
+	}
+	fmt.Fprintf(stderr, "snapshot %s stored\n", result.Snapshot.ID)
+
+	if policy.isSet() {
+		pruned, err := store.prune(policy, time.Now())
+		if err != nil {
+			fmt.Fprintf(stderr, "warning: pruning snapshots: %v\n", err)
+		}
+		for _, snap := range pruned {
+			fmt.Fprintf(stderr, "snapshot %s removed\n", snap.ID)
+		}
+	}
+}
+
+func runDiffCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	oldPath := fs.String("old", "", "previous export, in any output format, or a stored snapshot as snapshot:<id>")
+	newPath := fs.String("new", "", "current export, in any output format, or a stored snapshot as snapshot:<id> (default the inventory fetched from the API)")
+	login := fs.String("login", "", "Nicmanager API user (account.user), to compare with the current inventory")
+	password := fs.String("password", "", "Nicmanager API password, visible to other users of the system (default from -password-file, -password-command, -password-env, else the credential store)")
+	passwordFlags := addPasswordFlags(fs)
+	apiURL := fs.String("api-url", nicmanager.DefaultBaseURL, "base URL of the Nicmanager API")
+	timezone := fs.String("timezone", "Local", "IANA time zone the dates of the exports are compared in")
+	dateFormat := fs.String("date-format", "", "date format of CSV exports written with a custom -date-format, e.g. DD.MM.YY")
+	output := fs.String("output", "-", "report file, - writes to stdout")
+	format := fs.String("format", "", "report format csv or json (default from the output file extension, else csv)")
+	configPath := fs.String("config", "", "config file (default nicmanager-export/config.toml in the user config directory)")
+	profile := fs.String("profile", "", "named profile of the config file for login, time zone, columns and CSV dialect (default $"+profileEnvVar+")")
+	debug := fs.Bool("debug", false, "write the debug log to stderr")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	config, configErr := loadConfig(*configPath)
+	if configErr != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", configErr)
+		return exitUsage
+	}
+	settings, profileErr := config.profile(*profile)
+	if profileErr != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", profileErr)
+		return exitUsage
+	}
+	applyProfile(fs, settings, login, timezone, nil)
+	if *password == "" && *newPath == "" {
+		var pwErr error
+		if *password, pwErr = lookupPassword(*login, passwordFlags.source(settings), config.Credentials, stderr); pwErr != nil {
+			fmt.Fprintf(stderr, "diff: %v\n", pwErr)
+			return exitUsage
+		}
+	}
+
+	if *oldPath == "" {
+		fmt.Fprintln(stderr, "diff: -old is required")
+		fs.Usage()
+		return exitUsage
+	}
+	if *newPath == "" && (*login == "" || *password == "") {
+		fmt.Fprintln(stderr, "diff: -new or -login and -password to fetch the current inventory are required")
+		fs.Usage()
+		return exitUsage
+	}
+
+	reportFormat := formatCSV
+	pathFormat, pathHasFormat := formatFromPath(*output)
+	if *format != "" {
+		reportFormat = outputFormat(strings.ToLower(*format))
+	} else if pathHasFormat {
+		reportFormat = pathFormat
+	}
+	if reportFormat != formatCSV && reportFormat != formatJSON {
+		fmt.Fprintf(stderr, "diff: unknown report format %q, expected csv or json\n", reportFormat)
+		return exitUsage
+	}
+	if pathHasFormat && pathFormat != reportFormat {
+		fmt.Fprintf(stderr, "diff: -format %s does not match the extension of %s\n", reportFormat, *output)
+		return exitUsage
+	}
+
+	columns, colErr := settings.columns()
+	dialect, dialectErr := settings.CSV.dialect()
+	if err := errors.Join(colErr, dialectErr); err != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", err)
+		return exitUsage
+	}
+
+	loc, tzErr := time.LoadLocation(*timezone)
+	if tzErr != nil {
+		fmt.Fprintf(stderr, "diff: unknown time zone %q\n", *timezone)
+		return exitUsage
+	}
+
+	// the store is only needed for snapshot references, without one they fail
+	store, _ := openSnapshotStore(config.Snapshots.Dir)
+	readOpts := readOptions{Location: loc, DateLayout: dialect.DateLayout, Columns: columns, Store: store}
+	if *dateFormat != "" {
+		layout, err := parseDateFormat(*dateFormat)
+		if err != nil {
+			fmt.Fprintf(stderr, "diff: %v\n", err)
+			return exitUsage
+		}
+		readOpts.DateLayout = layout
+	}
+
+	if *debug {
+		log.SetOutput(stderr)
+	} else {
+		log.SetOutput(io.Discard)
+	}
+
+	before, err := readSnapshot(*oldPath, readOpts)
+	if err != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", err)
+		return exitError
+	}
+
+	var after snapshot
+	if *newPath != "" {
+		after, err = readSnapshot(*newPath, readOpts)
+	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		after, err = fetchSnapshot(ctx, newAPIClient(*login, *password, nicmanager.WithBaseURL(*apiURL)), loc)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", err)
+		return exitCode(err)
+	}
+
+	report := diffSnapshots(before, after, loc)
+	if *output == "-" {
+		err = writeDiff(stdout, report, reportFormat, dialect)
+	} else {
+		err = writeDiffFile(*output, report, reportFormat, dialect)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", err)
+		return exitError
+	}
+
+	added, removed, changed := report.counts()
+	fmt.Fprintf(stderr, "%d added, %d removed, %d changed\n", added, removed, changed)
+	return exitOK
+}
+
+const snapshotsUsage = `Usage: nicmanager-export snapshots <list|show|prune> [flags]
+
+  list            list the stored snapshots, newest first
+  show <id>       show the counts of a snapshot, the ID may be latest
+  prune           remove old snapshots, see -keep and -keep-days
+
+Stored snapshots are exported with "export -snapshot <id>" and compared with
+"diff -old snapshot:<id>".
+`
+
+func runSnapshotsCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, snapshotsUsage)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("snapshots "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "", "config file (default nicmanager-export/config.toml in the user config directory)")
+	dir := fs.String("dir", "", "snapshot directory (default from the config file, else nicmanager-export/snapshots in the user data directory)")
+	var keep, keepDays *int
+	var dryRun *bool
+	if args[0] == "prune" {
+		keep = fs.Int("keep", 0, "number of newest snapshots to keep, 0 keeps any number (default from the config file)")
+		keepDays = fs.Int("keep-days", 0, "remove snapshots older than this many days, 0 keeps any age (default from the config file)")
+		dryRun = fs.Bool("dry-run", false, "only list the snapshots that would be removed")
+	}
+
+	switch args[0] {
+	case "list", "show", "prune":
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, snapshotsUsage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown snapshots command %q\n\n", args[0])
+		fmt.Fprint(stderr, snapshotsUsage)
+		return exitUsage
+	}
+
+	// the ID of show comes before the flags
+	rest := args[1:]
+	var id string
+	if args[0] == "show" {
+		if len(rest) == 0 || strings.HasPrefix(rest[0], "-") {
+			fmt.Fprintln(stderr, "snapshots show: the snapshot ID is required")
+			return exitUsage
+		}
+		id, rest = rest[0], rest[1:]
+	}
+	if err := fs.Parse(rest); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	config, configErr := loadConfig(*configPath)
+	if configErr != nil {
+		fmt.Fprintf(stderr, "snapshots: %v\n", configErr)
+		return exitUsage
+	}
+	if *dir == "" {
+		*dir = config.Snapshots.Dir
+	}
+	store, err := openSnapshotStore(*dir)
+	if err != nil {
+		fmt.Fprintf(stderr, "snapshots: %v\n", err)
+		return exitError
+	}
+
+	switch args[0] {
+	case "list":
+		snapshots, err := store.list()
+		if err != nil {
+			fmt.Fprintf(stderr, "snapshots: %v\n", err)
+			return exitError
+		}
+		for _, snap := range snapshots {
+			fmt.Fprintf(stdout, "%-20s %s %10d bytes\n", snap.ID, snap.Time.Local().Format("2006-01-02 15:04:05"), snap.Size)
+		}
+		fmt.Fprintf(stderr, "%d snapshots in %s\n", len(snapshots), store.dir)
+
+	case "show":
+		info, err := store.lookup(id)
+		if err != nil {
+			fmt.Fprintf(stderr, "snapshots: %v\n", err)
+			return exitUsage
+		}
+		summary, err := summarizeSnapshot(info.Path)
+		if err != nil {
+			fmt.Fprintf(stderr, "snapshots: %v\n", err)
+			return exitError
+		}
+		printSnapshotSummary(stdout, info, summary)
+
+	case "prune":
+		policy := config.Snapshots.retention()
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "keep":
+				policy.Keep = *keep
+			case "keep-days":
+				policy.KeepDays = *keepDays
+			}
+		})
+		if !policy.isSet() {
+			fmt.Fprintln(stderr, "snapshots prune: -keep or -keep-days is required, or keep or keep_days in the [snapshots] section of the config file")
+			return exitUsage
+		}
+
+		var removed []snapshotInfo
+		if *dryRun {
+			snapshots, listErr := store.list()
+			removed, err = policy.expired(snapshots, time.Now()), listErr
+		} else {
+			removed, err = store.prune(policy, time.Now())
+		}
+		for _, snap := range removed {
+			fmt.Fprintln(stdout, snap.ID)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "snapshots: %v\n", err)
+			return exitError
+		}
+		fmt.Fprintf(stderr, "%d snapshots removed\n", len(removed))
+	}
+	return exitOK
+}
+
+// printSnapshotSummary writes the details of a snapshot
+func printSnapshotSummary(out io.Writer, info snapshotInfo, summary snapshotSummary) {
+	fmt.Fprintf(out, "Snapshot:   %s\n", info.ID)
+	fmt.Fprintf(out, "Fetched:    %s\n", info.Time.Local().Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(out, "File:       %s (%d bytes)\n", info.Path, info.Size)
+	fmt.Fprintf(out, "Domains:    %d, %d with delete date\n", summary.Records, summary.Deleted)
+	for _, group := range []struct {
+		title  string
+		counts map[string]int
+	}{
+		{"Order status", summary.ByState},
+		{"TLD", summary.ByTLD},
+	} {
+		fmt.Fprintf(out, "\n%s:\n", group.title)
+		for _, key := range slices.Sorted(maps.Keys(group.counts)) {
+			fmt.Fprintf(out, "  %-20s %d\n", cmp.Or(key, "(none)"), group.counts[key])
+		}
+	}
+}
+
+// printColumns lists the keys and default labels of all columns
+func printColumns(out io.Writer) {
+	for _, col := range availableColumns {
+		fmt.Fprintf(out, "%-22s %s\n", col.Key, col.Label)
+	}
+	fmt.Fprintf(out, "%-22s %s\n", extraColumnPrefix+"<field>", "any other field of the API response, labeled with its name")
+}
+
+// exitCode maps an export error to the exit code scripts can react to
+func exitCode(err error) int {
+	var authErr *nicmanager.AuthError
+	var rateErr *nicmanager.RateLimitError
+	var statusErr *nicmanager.APIStatusError
+	var decodeErr *nicmanager.DecodeError
+	var dateErr *DateError
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, context.Canceled):
+		return exitCanceled
+	case errors.As(err, &authErr):
+		return exitAuth
+	case errors.As(err, &rateErr):
+		return exitRateLimit
+	case errors.As(err, &statusErr):
+		return exitAPIStatus
+	case errors.As(err, &decodeErr):
+		return exitDecode
+	case errors.As(err, &dateErr):
+		return exitData
+	default:
+		return exitError
+	}
+}
+
+const credentialsUsage = `Usage: nicmanager-export credentials <set|check|delete> -login <login> [flags]
+
+  set     store the password of the login, read from -password-file,
+          -password-command or -password-env if given, else from the first
+          line of stdin, without echo on a terminal
+  check   tell whether a password is stored for the login
+  delete  remove the stored password of the login
+
+The store is the OS keyring, or the encrypted file selected with store =
+"file" in the [credentials] section of the config file, which needs a
+passphrase in $` + passphraseEnvVar + ` or a key_file.
+`
+
+func runCredentialsCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, credentialsUsage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "set", "check", "delete":
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, credentialsUsage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown credentials command %q\n\n", args[0])
+		fmt.Fprint(stderr, credentialsUsage)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("credentials "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	login := fs.String("login", "", "Nicmanager API user (default from the profile)")
+	storeKind := fs.String("store", "", "credential store keyring or file (default from the config file, else keyring)")
+	configPath := fs.String("config", "", "config file (default nicmanager-export/config.toml in the user config directory)")
+	profile := fs.String("profile", "", "named profile of the config file (default $"+profileEnvVar+")")
+	var flags passwordFlags
+	if args[0] == "set" {
+		flags = addPasswordFlags(fs)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	command := "credentials " + args[0]
+	config, configErr := loadConfig(*configPath)
+	if configErr != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", command, configErr)
+		return exitUsage
+	}
+	settings, profileErr := config.profile(*profile)
+	if profileErr != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", command, profileErr)
+		return exitUsage
+	}
+	applyProfile(fs, settings, login, nil, nil)
+	if *login == "" {
+		fmt.Fprintf(stderr, "%s: -login is required\n", command)
+		return exitUsage
+	}
+
+	storeSettings := config.Credentials
+	if *storeKind != "" {
+		storeSettings.Store = *storeKind
+	}
+	if storeSettings.Store == "" {
+		storeSettings.Store = storeKeyring
+	}
+	store, err := openCredentialStore(storeSettings, nil)
+	if err == nil && store == nil {
+		err = errors.New("the credential store is disabled in the config file")
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", command, err)
+		return exitError
+	}
+
+	switch args[0] {
+	case "set":
+		// only explicit sources, the default environment variable is
+		// no reason to store a password
+		var password string
+		var ok bool
+		if *flags.file != "" || *flags.command != "" || *flags.env != "" {
+			password, ok, err = flags.source(settings).password(context.Background())
+		} else {
+			password, err = readPassword(os.Stdin, stderr)
+			ok = true
+		}
+		if err == nil && (!ok || password == "") {
+			err = errors.New("the password is empty")
+		}
+		if err == nil {
+			err = store.store(*login, password)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", command, err)
+			return exitError
+		}
+		fmt.Fprintf(stderr, "password of %s stored in the %s\n", *login, store.name())
+	case "check":
+		if _, err := store.lookup(*login); err != nil {
+			fmt.Fprintf(stderr, "%s: %s: %v\n", command, *login, err)
+			return exitError
+		}
+		fmt.Fprintf(stdout, "a password of %s is stored in the %s\n", *login, store.name())
+	case "delete":
+		if err := store.remove(*login); err != nil {
+			fmt.Fprintf(stderr, "%s: %s: %v\n", command, *login, err)
+			return exitError
+		}
+		fmt.Fprintf(stderr, "password of %s removed from the %s\n", *login, store.name())
+	}
+	return exitOK
+}
+
+// readPassword reads the password from the first line of in, without echo
+// and after a prompt on stderr if in is a terminal
+func readPassword(in *os.File, stderr io.Writer) (string, error) {
+	if fd := int(in.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(stderr, "Password: ")
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(stderr)
+		return string(password), err
+	}
+	return readPasswordLine(in)
+}
+
+// readPasswordLine reads the first line of in as password, a last line
+// without line ending is fine
+func readPasswordLine(in io.Reader) (string, error) {
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return firstLine([]byte(line)), nil
+}
@@ -0,0 +1,838 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariow/nicmanager-export/nicmanager"
+)
+
+func TestRunCLI(t *testing.T) {
+	isolateConfig(t)
+
+	t.Setenv(passwordEnvVar, "")
+
+	tests := []struct {
+		name         string
+		args         []string
+		expectedCode int
+		expectedOut  string
+		expectedErr  string
+	}{
+		{
+			name:         "no arguments",
+			args:         []string{},
+			expectedCode: exitUsage,
+			expectedErr:  "Usage:",
+		},
+		{
+			name:         "help",
+			args:         []string{"help"},
+			expectedCode: exitOK,
+			expectedOut:  "Commands:",
+		},
+		{
+			name:         "unknown command",
+			args:         []string{"import"},
+			expectedCode: exitUsage,
+			expectedErr:  `unknown command "import"`,
+		},
+		{
+			name:         "export without flags",
+			args:         []string{"export"},
+			expectedCode: exitUsage,
+			expectedErr:  "-login, -password and -output are required",
+		},
+		{
+			name:         "export with unknown flag",
+			args:         []string{"export", "-foo"},
+			expectedCode: exitUsage,
+			expectedErr:  "flag provided but not defined: -foo",
+		},
+		{
+			name:         "export with unknown time zone",
+			args:         []string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-timezone", "Mars/Olympus_Mons"},
+			expectedCode: exitUsage,
+			expectedErr:  `unknown time zone "Mars/Olympus_Mons"`,
+		},
+		{
+			name:         "export with unknown format",
+			args:         []string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-format", "xml"},
+			expectedCode: exitUsage,
+			expectedErr:  `unknown output format "xml"`,
+		},
+		{
+			name:         "export sqlite to stdout",
+			args:         []string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-format", "sqlite"},
+			expectedCode: exitUsage,
+			expectedErr:  "sqlite output needs a file",
+		},
+		{
+			name:         "export with invalid cutoff",
+			args:         []string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-cutoff", "01.03.2020"},
+			expectedCode: exitUsage,
+			expectedErr:  `invalid cutoff date "01.03.2020"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runCLI(tt.args, &stdout, &stderr)
+
+			assert.Equal(t, tt.expectedCode, code, "Exit code doesn't match, stderr: %s", stderr.String())
+			assert.Contains(t, stdout.String(), tt.expectedOut)
+			assert.Contains(t, stderr.String(), tt.expectedErr)
+		})
+	}
+}
+
+func TestRunCLI_PasswordFromEnvironment(t *testing.T) {
+	isolateConfig(t)
+	t.Setenv(passwordEnvVar, "secret")
+
+	var stdout, stderr bytes.Buffer
+	code := runCLI([]string{"export", "-login", "account.user", "-output", "-", "-cutoff", "bad"}, &stdout, &stderr)
+
+	// the password requirement is satisfied, so parsing fails on the cutoff date
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), "invalid cutoff date")
+}
+
+func TestRunCLI_PasswordSources(t *testing.T) {
+	dir := isolateConfig(t)
+	t.Setenv(passwordEnvVar, "")
+	t.Setenv(passphraseEnvVar, "correct horse")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, _ := r.BasicAuth(); password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	passwordFile := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("secret\n"), 0o600))
+	config := writeConfig(t, fmt.Sprintf("[credentials]\nstore = \"file\"\nfile = %q\n", filepath.Join(dir, "credentials.enc")))
+	exportArgs := []string{"export", "-login", "account.user", "-output", "-", "-no-snapshot", "-config", config, "-api-url", server.URL}
+
+	t.Run("password file", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(append(exportArgs, "-password-file", passwordFile), &stdout, &stderr)
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+	})
+
+	t.Run("missing password file", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(append(exportArgs, "-password-file", filepath.Join(dir, "missing")), &stdout, &stderr)
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr.String(), "password file")
+	})
+
+	t.Run("nothing stored", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(exportArgs, &stdout, &stderr)
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr.String(), "required")
+	})
+
+	t.Run("credential store", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI([]string{"credentials", "set", "-login", "account.user", "-config", config, "-password-file", passwordFile}, &stdout, &stderr)
+		require.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+		assert.NotContains(t, stderr.String(), "secret")
+
+		stdout.Reset()
+		stderr.Reset()
+		code = runCLI([]string{"credentials", "check", "-login", "account.user", "-config", config}, &stdout, &stderr)
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+		assert.NotContains(t, stdout.String(), "secret", "The password must not be printed")
+
+		stdout.Reset()
+		stderr.Reset()
+		code = runCLI(exportArgs, &stdout, &stderr)
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+
+		stdout.Reset()
+		stderr.Reset()
+		code = runCLI([]string{"credentials", "delete", "-login", "account.user", "-config", config}, &stdout, &stderr)
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+
+		stdout.Reset()
+		stderr.Reset()
+		code = runCLI([]string{"credentials", "check", "-login", "account.user", "-config", config}, &stdout, &stderr)
+		assert.Equal(t, exitError, code)
+		assert.Contains(t, stderr.String(), "no stored password")
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI([]string{"credentials", "set", "-login", "account.user", "-config", config, "-password-file", passwordFile}, &stdout, &stderr)
+		require.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+
+		t.Setenv(passphraseEnvVar, "wrong")
+		stdout.Reset()
+		stderr.Reset()
+		code = runCLI(exportArgs, &stdout, &stderr)
+		assert.Equal(t, exitUsage, code, "Without a readable store the password is missing")
+		assert.Contains(t, stderr.String(), "warning: credential store")
+	})
+}
+
+func TestReadPasswordLine(t *testing.T) {
+	// like a terminal, the writer stays open after the first line
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	go writer.Write([]byte("secret\r\nsecond line\n"))
+
+	password, err := readPasswordLine(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", password)
+
+	password, err = readPasswordLine(strings.NewReader("no line ending"))
+	require.NoError(t, err)
+	assert.Equal(t, "no line ending", password)
+}
+
+func TestRunCLI_CredentialsUsage(t *testing.T) {
+	isolateConfig(t)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"credentials"}, "Usage: nicmanager-export credentials"},
+		{[]string{"credentials", "list"}, `unknown credentials command "list"`},
+		{[]string{"credentials", "check"}, "-login is required"},
+		{[]string{"credentials", "check", "-login", "account.user", "-store", "vault"}, `unknown credential store "vault"`},
+		{[]string{"credentials", "check", "-login", "account.user", "-store", "none"}, "disabled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.args), func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runCLI(tt.args, &stdout, &stderr)
+			assert.NotEqual(t, exitOK, code)
+			assert.Contains(t, stderr.String(), tt.expected)
+		})
+	}
+}
+
+func TestRunCLI_Export(t *testing.T) {
+	isolateConfig(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name":"example.com","order_status":"active","order_datetime":"2023-01-01T00:00:00Z","registration_datetime":"2023-01-02T00:00:00Z","delete_datetime":""}]`))
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := runCLI([]string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-cutoff", "2023-06-01", "-timezone", "UTC", "-api-url", server.URL}, &stdout, &stderr)
+
+	assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+	assert.Equal(t, "Domain,Order Date,Reg Date,Close Date\nexample.com,2023-01-01,2023-01-02,\n", stdout.String())
+	assert.Contains(t, stderr.String(), "1 records written")
+}
+
+func TestRunCLI_ExportAuthError(t *testing.T) {
+	isolateConfig(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "export.csv")
+
+	var stdout, stderr bytes.Buffer
+	code := runCLI([]string{"export", "-login", "account.user", "-password", "wrong", "-output", output, "-api-url", server.URL}, &stdout, &stderr)
+
+	assert.Equal(t, exitAuth, code)
+	assert.Contains(t, stderr.String(), "401 Unauthorized")
+	assert.NoFileExists(t, output)
+}
+
+func TestRunCLI_ExportKeepPartialFormat(t *testing.T) {
+	isolateConfig(t)
+
+	for _, format := range []string{"xlsx", "ods", "sqlite"} {
+		t.Run(format, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "export."+format)
+
+			var stdout, stderr bytes.Buffer
+			code := runCLI([]string{"export", "-login", "account.user", "-password", "secret", "-output", output, "-keep-partial"}, &stdout, &stderr)
+			assert.Equal(t, exitUsage, code)
+			assert.Contains(t, stderr.String(), "-keep-partial works with csv, json and ndjson only")
+		})
+	}
+}
+
+func TestRunCLI_ExportUnwritableOutput(t *testing.T) {
+	isolateConfig(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("The API must not be called for an unwritable output path")
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "missing", "export.csv")
+
+	var stdout, stderr bytes.Buffer
+	code := runCLI([]string{"export", "-login", "account.user", "-password", "secret", "-output", output, "-api-url", server.URL}, &stdout, &stderr)
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "cannot write to")
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"no error", nil, exitOK},
+		{"canceled", fmt.Errorf("nicmanager: %w", context.Canceled), exitCanceled},
+		{"auth", &nicmanager.AuthError{}, exitAuth},
+		{"rate limit", &nicmanager.RateLimitError{}, exitRateLimit},
+		{"status", &nicmanager.APIStatusError{StatusCode: 502}, exitAPIStatus},
+		{"decode", &nicmanager.DecodeError{Err: errors.New("unexpected EOF")}, exitDecode},
+		{"wrapped auth", fmt.Errorf("export: %w", &nicmanager.AuthError{}), exitAuth},
+		{"other", errors.New("disk full"), exitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, exitCode(tt.err))
+		})
+	}
+}
+
+func TestRunCLI_ExportFormatFromExtension(t *testing.T) {
+	isolateConfig(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name":"example.com","order_status":"active","order_datetime":"2023-01-01T00:00:00Z","registration_datetime":"2023-01-02T00:00:00Z","delete_datetime":""}]`))
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "export.ndjson")
+
+	var stdout, stderr bytes.Buffer
+	code := runCLI([]string{"export", "-login", "account.user", "-password", "secret", "-output", output, "-api-url", server.URL}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"example.com","order_status":"active","order_datetime":"2023-01-01T00:00:00Z","registration_datetime":"2023-01-02T00:00:00Z","delete_datetime":""}`+"\n", string(content))
+}
+
+func TestRunCLI_FormatExtensionMismatch(t *testing.T) {
+	isolateConfig(t)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"export", "-login", "account.user", "-password", "secret", "-format", "json", "-output", "out.csv"}, "export: -format json does not match the extension of out.csv"},
+		{[]string{"diff", "-old", "a.csv", "-new", "b.csv", "-format", "json", "-output", "report.csv"}, "diff: -format json does not match the extension of report.csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args[0], func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runCLI(tt.args, &stdout, &stderr)
+			assert.Equal(t, exitUsage, code)
+			assert.Contains(t, stderr.String(), tt.expected)
+		})
+	}
+}
+
+func TestRunCLI_Columns(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCLI([]string{"columns"}, &stdout, &stderr)
+
+	assert.Equal(t, exitOK, code)
+	for _, key := range columnKeys() {
+		assert.Contains(t, stdout.String(), key)
+	}
+}
+
+func TestRunCLI_ExportColumns(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name":"example.com","order_status":"active","order_datetime":"2023-01-01T00:00:00Z","registration_datetime":"2023-01-02T00:00:00Z","delete_datetime":""}]`))
+	}))
+	defer server.Close()
+
+	baseArgs := []string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-cutoff", "2023-06-01", "-timezone", "UTC", "-api-url", server.URL}
+	config := writeConfig(t, `columns = ["name", "order_status=Status"]`)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"columns flag", []string{"-columns", "tld,name=Domainname"}, "TLD,Domainname\ncom,example.com\n"},
+		{"config file", []string{"-config", config}, "Domain,Status\nexample.com,active\n"},
+		{"flag overrides config file", []string{"-config", config, "-columns", "sld"}, "SLD\nexample\n"},
+		{"CSV preset", []string{"-csv-preset", "de-DE Excel"}, "\ufeffDomain;Order Date;Reg Date;Close Date\r\nexample.com;01.01.2023;02.01.2023;\r\n"},
+		{"CSV settings override the preset", []string{"-csv-preset", "de-DE Excel", "-csv-bom=false", "-csv-line-ending", "lf", "-csv-quote", "all", "-date-format", "DD.MM.YY", "-columns", "name,order_date"}, "\"Domain\";\"Order Date\"\n\"example.com\";\"01.01.23\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateConfig(t)
+
+			var stdout, stderr bytes.Buffer
+			code := runCLI(append(baseArgs, tt.args...), &stdout, &stderr)
+
+			assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+			assert.Equal(t, tt.expected, stdout.String())
+		})
+	}
+}
+
+func TestRunCLI_ExportProfile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name":"example.com","order_datetime":"2023-01-01T00:00:00Z"},{"name":"example.de","order_datetime":"2023-01-01T00:00:00Z"}]`))
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	config := writeConfig(t, fmt.Sprintf(`
+[profiles.customer-a]
+login = "customer-a.export"
+output_dir = %q
+format = "ndjson"
+time_zone = "UTC"
+
+[profiles.customer-a.filter]
+tld = ["de"]
+`, outputDir))
+	baseArgs := []string{"export", "-password", "secret", "-cutoff", "2023-06-01", "-no-snapshot", "-config", config, "-api-url", server.URL}
+
+	t.Run("profile", func(t *testing.T) {
+		isolateConfig(t)
+
+		var stdout, stderr bytes.Buffer
+		code := runCLI(append(baseArgs, "-profile", "customer-a"), &stdout, &stderr)
+		require.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+
+		content, err := os.ReadFile(filepath.Join(outputDir, "Export_2023-06-01.ndjson"))
+		require.NoError(t, err, "Without -output the file should be named after the cutoff date in the output directory")
+		assert.Contains(t, string(content), "example.de")
+		assert.NotContains(t, string(content), "example.com", "The filter of the profile should apply")
+	})
+
+	t.Run("environment and flags", func(t *testing.T) {
+		isolateConfig(t)
+		t.Setenv(profileEnvVar, "customer-a")
+		t.Setenv("NICMANAGER_FORMAT", "json")
+
+		var stdout, stderr bytes.Buffer
+		code := runCLI(append(baseArgs, "-output", "custom.csv", "-tld", "com"), &stdout, &stderr)
+		require.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+
+		content, err := os.ReadFile(filepath.Join(outputDir, "custom.csv"))
+		require.NoError(t, err, "A relative -output should be written to the output directory")
+		assert.Equal(t, "Domain,Order Date,Reg Date,Close Date\nexample.com,2023-01-01,,\n", string(content), "The file extension and flags should win over the profile")
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		isolateConfig(t)
+
+		var stdout, stderr bytes.Buffer
+		code := runCLI(append(baseArgs, "-profile", "customer-b", "-output", "-"), &stdout, &stderr)
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr.String(), `unknown profile "customer-b"`)
+	})
+}
+
+func TestRunCLI_ExportInvalidCSVDialect(t *testing.T) {
+	isolateConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	code := runCLI([]string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-csv-delimiter", ";;"}, &stdout, &stderr)
+
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), `invalid CSV delimiter ";;"`)
+}
+
+func TestRunCLI_ExportInvalidColumns(t *testing.T) {
+	isolateConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	code := runCLI([]string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-columns", "name,price"}, &stdout, &stderr)
+
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), `unknown column "price"`)
+}
+
+func TestRunCLI_ExportFilter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[` +
+			`{"name":"example.com","order_status":"active","registration_datetime":"2020-05-01T00:00:00Z"},` +
+			`{"name":"example.net","order_status":"active","registration_datetime":"2018-05-01T00:00:00Z"},` +
+			`{"name":"shop.de","order_status":"transfer","registration_datetime":"2021-05-01T00:00:00Z"}]`))
+	}))
+	defer server.Close()
+
+	baseArgs := []string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-cutoff", "2023-06-01", "-timezone", "UTC", "-api-url", server.URL, "-columns", "name"}
+	config := writeConfig(t, "[filter]\ntld = [\"com\", \"net\"]\n")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"no filter", nil, "Domain\nexample.com\nexample.net\nshop.de\n"},
+		{"TLD and registration date", []string{"-tld", "com,net", "-registered", "2019-01-01.."}, "Domain\nexample.com\n"},
+		{"status", []string{"-status", "transfer"}, "Domain\nshop.de\n"},
+		{"name glob", []string{"-name", "example.*"}, "Domain\nexample.com\nexample.net\n"},
+		{"name regex", []string{"-name-regex", `\.de$`}, "Domain\nshop.de\n"},
+		{"config file", []string{"-config", config}, "Domain\nexample.com\nexample.net\n"},
+		{"config file and flags", []string{"-config", config, "-registered", "..2019-12-31"}, "Domain\nexample.net\n"},
+		{"flag overrides config file", []string{"-config", config, "-tld", "de"}, "Domain\nshop.de\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateConfig(t)
+
+			var stdout, stderr bytes.Buffer
+			code := runCLI(append(baseArgs, tt.args...), &stdout, &stderr)
+
+			assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+			assert.Equal(t, tt.expected, stdout.String())
+		})
+	}
+}
+
+func TestRunCLI_ExportInvalidFilter(t *testing.T) {
+	isolateConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	code := runCLI([]string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-registered", "2019"}, &stdout, &stderr)
+
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), `invalid date range "2019"`)
+}
+
+func TestRunCLI_ExportModes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[` +
+			`{"name":"old.com","registration_datetime":"2020-01-01T00:00:00Z"},` +
+			`{"name":"new.com","registration_datetime":"2023-07-01T00:00:00Z"},` +
+			`{"name":"gone.com","registration_datetime":"2020-01-01T00:00:00Z","delete_datetime":"2022-06-01T00:00:00Z"},` +
+			`{"name":"left.com","registration_datetime":"2020-01-01T00:00:00Z","delete_datetime":"2023-03-01T00:00:00Z"}]`))
+	}))
+	defer server.Close()
+
+	baseArgs := []string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-cutoff", "2023-06-01", "-timezone", "UTC", "-api-url", server.URL, "-columns", "name"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"cutoff is the default", nil, "Domain\nold.com\nnew.com\n"},
+		{"as-of", []string{"-mode", "as-of"}, "Domain\nold.com\n"},
+		{"between", []string{"-mode", "between", "-from", "2023-01-01"}, "Domain\nold.com\nleft.com\n"},
+		{"between from the day before a deletion", []string{"-mode", "between", "-from", "2022-05-31"}, "Domain\nold.com\ngone.com\nleft.com\n"},
+		{"between from the day of a deletion", []string{"-mode", "between", "-from", "2022-06-01"}, "Domain\nold.com\nleft.com\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateConfig(t)
+
+			var stdout, stderr bytes.Buffer
+			code := runCLI(append(baseArgs, tt.args...), &stdout, &stderr)
+
+			assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+			assert.Equal(t, tt.expected, stdout.String())
+		})
+	}
+}
+
+func TestRunCLI_ExportInvalidMode(t *testing.T) {
+	isolateConfig(t)
+
+	baseArgs := []string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-cutoff", "2023-06-01"}
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-mode", "latest"}, `unknown mode "latest"`},
+		{[]string{"-mode", "between"}, "-mode between needs -from"},
+		{[]string{"-from", "2023-01-01"}, "-from is only used with -mode between"},
+		{[]string{"-mode", "between", "-from", "01.01.2023"}, `invalid -from date "01.01.2023"`},
+		{[]string{"-mode", "between", "-from", "2023-06-02"}, "-from 2023-06-02 is after the cutoff date 2023-06-01"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(append(baseArgs, tt.args...), &stdout, &stderr)
+
+		assert.Equal(t, exitUsage, code, "args %v", tt.args)
+		assert.Contains(t, stderr.String(), tt.expected)
+	}
+}
+
+func TestRunCLI_Diff(t *testing.T) {
+	isolateConfig(t)
+	dir := t.TempDir()
+
+	oldPath := filepath.Join(dir, "last-month.csv")
+	require.NoError(t, os.WriteFile(oldPath, []byte("Domain,Order Date,Reg Date,Close Date\n"+
+		"example.com,2023-01-01,2023-01-02,\n"+
+		"old.de,2020-01-01,2020-01-02,\n"), 0o600))
+	newPath := filepath.Join(dir, "this-month.ndjson")
+	require.NoError(t, os.WriteFile(newPath, []byte(
+		`{"name":"example.com","order_status":"deleted","order_datetime":"2023-01-01T00:00:00Z","registration_datetime":"2023-01-02T00:00:00Z","delete_datetime":"2024-03-01T00:00:00Z"}`+"\n"+
+			`{"name":"new.org","order_status":"active","order_datetime":"2024-02-01T00:00:00Z"}`+"\n"), 0o600))
+
+	expected := "Change,Domain,Field,Old,New\n" +
+		"changed,example.com,delete_date,,2024-03-01\n" +
+		"added,new.org,,,\n" +
+		"removed,old.de,,,\n"
+
+	t.Run("two exports", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI([]string{"diff", "-old", oldPath, "-new", newPath, "-timezone", "UTC"}, &stdout, &stderr)
+
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+		assert.Equal(t, expected, stdout.String())
+		assert.Contains(t, stderr.String(), "1 added, 1 removed, 1 changed")
+	})
+
+	t.Run("export and current inventory", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"name":"example.com","order_status":"deleted","order_datetime":"2023-01-01T00:00:00Z","registration_datetime":"2023-01-02T00:00:00Z","delete_datetime":"2099-03-01T00:00:00Z"},` +
+				`{"name":"new.org","order_status":"active"},` +
+				`{"name":"gone.net","delete_datetime":"2024-01-01T00:00:00Z"}]`))
+		}))
+		defer server.Close()
+
+		reportPath := filepath.Join(dir, "report.json")
+		var stdout, stderr bytes.Buffer
+		code := runCLI([]string{"diff", "-old", oldPath, "-login", "account.user", "-password", "secret", "-api-url", server.URL, "-timezone", "UTC", "-output", reportPath}, &stdout, &stderr)
+
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+		report, err := os.ReadFile(reportPath)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"name": "example.com", "change": "changed", "fields": [{"field": "delete_date", "old": "", "new": "2099-03-01"}]},
+			{"name": "new.org", "change": "added"},
+			{"name": "old.de", "change": "removed"}
+		]`, string(report), "Domains deleted by now should not be in the current inventory")
+	})
+}
+
+func TestRunCLI_DiffUsage(t *testing.T) {
+	isolateConfig(t)
+	t.Setenv(passwordEnvVar, "")
+
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"diff"}, exitUsage, "-old is required"},
+		{[]string{"diff", "-old", "a.csv"}, exitUsage, "-new or -login and -password"},
+		{[]string{"diff", "-old", "a.csv", "-new", "b.csv", "-format", "xlsx"}, exitUsage, `unknown report format "xlsx"`},
+		{[]string{"diff", "-old", "a.csv", "-new", "b.csv", "-date-format", "hh:mm"}, exitUsage, "invalid date format"},
+		{[]string{"diff", "-old", filepath.Join(t.TempDir(), "a.csv"), "-new", "b.csv"}, exitError, "a.csv"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(tt.args, &stdout, &stderr)
+
+		assert.Equal(t, tt.code, code, "args %v", tt.args)
+		assert.Contains(t, stderr.String(), tt.expected)
+	}
+}
+
+func TestRunCLI_Snapshots(t *testing.T) {
+	dir := isolateConfig(t)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`[{"name":"example.com","order_status":"active","registration_datetime":"2023-01-02T00:00:00Z"},` +
+			`{"name":"gone.de","order_status":"deleted","delete_datetime":"2023-03-01T00:00:00Z"}]`))
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := runCLI([]string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-cutoff", "2023-06-01", "-timezone", "UTC", "-api-url", server.URL}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+	assert.Contains(t, stderr.String(), "snapshot ")
+	assert.Contains(t, stdout.String(), "example.com")
+	assert.NotContains(t, stdout.String(), "gone.de")
+	fetches := requests
+
+	t.Run("list", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI([]string{"snapshots", "list"}, &stdout, &stderr)
+
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+		assert.Regexp(t, `^\d{8}T\d{6}Z `, stdout.String())
+		assert.Contains(t, stderr.String(), "1 snapshots in "+filepath.Join(dir, configDirName, "snapshots"))
+	})
+
+	t.Run("show", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI([]string{"snapshots", "show", "latest"}, &stdout, &stderr)
+
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+		assert.Contains(t, stdout.String(), "Domains:    2, 1 with delete date")
+		assert.Regexp(t, `deleted\s+1`, stdout.String())
+	})
+
+	t.Run("re-export without the API", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "export.json")
+		var stdout, stderr bytes.Buffer
+		code := runCLI([]string{"export", "-snapshot", "latest", "-output", output, "-cutoff", "2023-01-01", "-timezone", "UTC"}, &stdout, &stderr)
+
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+		assert.Equal(t, fetches, requests, "A snapshot export should not call the API")
+		data, err := os.ReadFile(output)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "gone.de", "The snapshot holds the whole fetched list, the cutoff applies on export")
+		assert.NotContains(t, stderr.String(), "snapshot 2", "Exporting a snapshot should not store another one")
+	})
+
+	t.Run("diff with a snapshot", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI([]string{"diff", "-old", "snapshot:latest", "-login", "account.user", "-password", "secret", "-api-url", server.URL}, &stdout, &stderr)
+
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+		assert.Equal(t, "Change,Domain,Field,Old,New\n", stdout.String(), "The API data is unchanged since the snapshot")
+	})
+
+	t.Run("diff a snapshot with an export", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "export.csv")
+		var stdout, stderr bytes.Buffer
+		code := runCLI([]string{"export", "-snapshot", "latest", "-output", output, "-timezone", "UTC"}, &stdout, &stderr)
+		require.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+
+		stdout.Reset()
+		stderr.Reset()
+		code = runCLI([]string{"diff", "-old", "snapshot:latest", "-new", output, "-timezone", "UTC"}, &stdout, &stderr)
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+		assert.Equal(t, "Change,Domain,Field,Old,New\n", stdout.String(), "A default export of the snapshot is the same inventory")
+	})
+
+	t.Run("unknown snapshot", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI([]string{"export", "-snapshot", "19990101T000000Z", "-output", "-"}, &stdout, &stderr)
+
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr.String(), `no snapshot "19990101T000000Z"`)
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI([]string{"snapshots", "prune", "-keep", "1"}, &stdout, &stderr)
+
+		assert.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+		assert.Contains(t, stderr.String(), "0 snapshots removed", "The newest snapshot is always kept")
+	})
+}
+
+func TestRunCLI_ExportNoSnapshot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name":"example.com"}]`))
+	}))
+	defer server.Close()
+
+	baseArgs := []string{"export", "-login", "account.user", "-password", "secret", "-output", "-", "-api-url", server.URL}
+	disabled := writeConfig(t, "[snapshots]\ndisabled = true\n")
+
+	for _, args := range [][]string{{"-no-snapshot"}, {"-config", disabled}} {
+		isolateConfig(t)
+
+		var stdout, stderr bytes.Buffer
+		code := runCLI(append(baseArgs, args...), &stdout, &stderr)
+		require.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+
+		var listOut, listErr bytes.Buffer
+		runCLI([]string{"snapshots", "list"}, &listOut, &listErr)
+		assert.Empty(t, listOut.String(), "args %v: no snapshot should be stored", args)
+	}
+}
+
+func TestRunCLI_SnapshotsRetention(t *testing.T) {
+	isolateConfig(t)
+	snapshotDir := t.TempDir()
+	config := writeConfig(t, fmt.Sprintf("[snapshots]\ndir = %q\nkeep = 2\n", snapshotDir))
+
+	store := &snapshotStore{dir: snapshotDir}
+	now := time.Now()
+	for _, daysAgo := range []int{3, 2, 1} {
+		storeSnapshot(t, store, now.AddDate(0, 0, -daysAgo), testRecords(t))
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runCLI([]string{"snapshots", "prune", "-config", config, "-dry-run"}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+	assert.Equal(t, now.AddDate(0, 0, -3).UTC().Format(snapshotIDLayout)+"\n", stdout.String())
+
+	snapshots, err := store.list()
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 3, "A dry run should remove nothing")
+
+	// an export applies the retention policy of the config file
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name":"example.com"}]`))
+	}))
+	defer server.Close()
+	stdout.Reset()
+	stderr.Reset()
+	code = runCLI([]string{"export", "-config", config, "-login", "account.user", "-password", "secret", "-output", "-", "-api-url", server.URL}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, "stderr: %s", stderr.String())
+
+	snapshots, err = store.list()
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+}
+
+func TestRunCLI_SnapshotsUsage(t *testing.T) {
+	isolateConfig(t)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"snapshots"}, "Usage: nicmanager-export snapshots"},
+		{[]string{"snapshots", "remove"}, `unknown snapshots command "remove"`},
+		{[]string{"snapshots", "show"}, "the snapshot ID is required"},
+		{[]string{"snapshots", "show", "latest"}, `no snapshot "latest"`},
+		{[]string{"snapshots", "prune"}, "-keep or -keep-days is required"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(tt.args, &stdout, &stderr)
+
+		assert.Equal(t, exitUsage, code, "args %v", tt.args)
+		assert.Contains(t, stderr.String(), tt.expected)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// columnKind tells the tabular writers which cell type a column needs
+type columnKind int
+
+const (
+	columnText columnKind = iota
+	columnDate
+	columnNumber
+)
+
+// column is one column of the tabular output formats (CSV, XLSX, ODS).
+// value returns nil for an empty cell, otherwise a string, a time.Time for
+// date columns or an int for number columns.
+type column struct {
+	Key   string
+	Label string
+	Kind  columnKind
+	value func(record *exportRecord, opts *exportOptions) any
+}
+
+// availableColumns are all columns in the order they are offered, with their
+// default labels. The raw timestamps keep the API value, the date columns
+// hold the calendar day in the export time zone.
+var availableColumns = []column{
+	{Key: "name", Label: "Domain", value: func(r *exportRecord, _ *exportOptions) any { return r.Domain.Name }},
+	{Key: "order_status", Label: "Order Status", value: func(r *exportRecord, _ *exportOptions) any { return r.Domain.OrderStatus }},
+	{Key: "order_date", Label: "Order Date", Kind: columnDate, value: func(r *exportRecord, _ *exportOptions) any { return r.Dates.Order }},
+	{Key: "registration_date", Label: "Reg Date", Kind: columnDate, value: func(r *exportRecord, _ *exportOptions) any { return r.Dates.Registration }},
+	{Key: "delete_date", Label: "Close Date", Kind: columnDate, value: func(r *exportRecord, _ *exportOptions) any { return r.Dates.Delete }},
+	{Key: "order_datetime", Label: "Order Timestamp", value: func(r *exportRecord, _ *exportOptions) any { return r.Domain.OrderDateTime }},
+	{Key: "registration_datetime", Label: "Reg Timestamp", value: func(r *exportRecord, _ *exportOptions) any { return r.Domain.RegistrationDateTime }},
+	{Key: "delete_datetime", Label: "Close Timestamp", value: func(r *exportRecord, _ *exportOptions) any { return r.Domain.DeleteDateTime }},
+	{Key: "tld", Label: "TLD", value: func(r *exportRecord, _ *exportOptions) any { return r.Domain.TLD() }},
+	{Key: "sld", Label: "SLD", value: func(r *exportRecord, _ *exportOptions) any { return r.Domain.SLD() }},
+	{Key: "days_in_portfolio", Label: "Days in Portfolio", Kind: columnNumber, value: daysInPortfolio},
+}
+
+// extraColumnPrefix selects a field of Domain.Extra as column, e.g.
+// extra.auth_code_status
+const extraColumnPrefix = "extra."
+
+// defaultColumnSpec are the four columns the export always had
+const defaultColumnSpec = "name,order_date,registration_date,delete_date"
+
+// defaultColumns are the columns used if no column spec is given
+var defaultColumns = mustParseColumns(defaultColumnSpec)
+
+// columnKeys lists the keys of all available columns
+func columnKeys() []string {
+	keys := make([]string, len(availableColumns))
+	for i, col := range availableColumns {
+		keys[i] = col.Key
+	}
+	return keys
+}
+
+// lookupColumn returns the available column with the given key, keys with
+// extraColumnPrefix select the API field of that name
+func lookupColumn(key string) (column, bool) {
+	for _, col := range availableColumns {
+		if col.Key == key {
+			return col, true
+		}
+	}
+	if field, ok := strings.CutPrefix(key, extraColumnPrefix); ok && field != "" {
+		return extraColumn(field), true
+	}
+	return column{}, false
+}
+
+// extraColumn is the column of the API field name that Domain does not map,
+// labeled with the field name
+func extraColumn(name string) column {
+	return column{
+		Key:   extraColumnPrefix + name,
+		Label: name,
+		value: func(r *exportRecord, _ *exportOptions) any {
+			raw, ok := r.Domain.Extra[name]
+			if !ok {
+				return nil
+			}
+			return extraValue(raw)
+		},
+	}
+}
+
+// extraValue turns a raw JSON value into cell text: strings without quotes,
+// null as empty cell, anything else as compact JSON
+func extraValue(raw json.RawMessage) any {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return string(raw)
+	}
+	if compact.String() == "null" {
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	return compact.String()
+}
+
+// parseColumns parses a column spec, a comma separated list of column keys
+// in output order, each optionally followed by =Label to set the header,
+// e.g. "name=Domain,tld,order_status=Status". An empty spec selects the
+// default columns.
+func parseColumns(spec string) ([]column, error) {
+	if strings.TrimSpace(spec) == "" {
+		return defaultColumns, nil
+	}
+	return parseColumnList(strings.Split(spec, ","))
+}
+
+// parseColumnList parses a column spec given as list, one column per entry
+func parseColumnList(entries []string) ([]column, error) {
+	var columns []column
+	for _, entry := range entries {
+		key, label, hasLabel := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		// API field names are kept as they are, only the prefix is case insensitive
+		if field, ok := cutPrefixFold(key, extraColumnPrefix); ok {
+			key = extraColumnPrefix + field
+		} else {
+			key = strings.ToLower(key)
+		}
+
+		col, ok := lookupColumn(key)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, available columns: %s and %s<field>", key, strings.Join(columnKeys(), ", "), extraColumnPrefix)
+		}
+		if hasLabel {
+			col.Label = strings.TrimSpace(label)
+		}
+		columns = append(columns, col)
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("no columns selected")
+	}
+	return columns, nil
+}
+
+// cutPrefixFold is strings.CutPrefix ignoring case
+func cutPrefixFold(s string, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+func mustParseColumns(spec string) []column {
+	columns, err := parseColumnList(strings.Split(spec, ","))
+	if err != nil {
+		panic(err)
+	}
+	return columns
+}
+
+// formatColumnSpec turns columns back into a spec parseColumns accepts,
+// labels are only written where they differ from the default
+func formatColumnSpec(columns []column) string {
+	entries := make([]string, len(columns))
+	for i, col := range columns {
+		entries[i] = col.Key
+		if def, ok := lookupColumn(col.Key); ok && def.Label != col.Label {
+			entries[i] += "=" + col.Label
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+// columnLabels returns the header row for columns
+func columnLabels(columns []column) []string {
+	labels := make([]string, len(columns))
+	for i, col := range columns {
+		labels[i] = col.Label
+	}
+	return labels
+}
+
+// formatCell formats a column value as text, as written to CSV
+func formatCell(value any, loc *time.Location) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return formatDate(v, loc)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// daysInPortfolio counts the calendar days in the export time zone from the
+// registration (or, lacking one, the order) of a domain until its deletion,
+// or until the cutoff day if it was not deleted by then
+func daysInPortfolio(record *exportRecord, opts *exportOptions) any {
+	start := record.Dates.Registration
+	if start.IsZero() {
+		start = record.Dates.Order
+	}
+	if start.IsZero() {
+		return nil
+	}
+
+	end := opts.CutoffDate
+	if !record.Dates.Delete.IsZero() && (end.IsZero() || record.Dates.Delete.Before(end)) {
+		end = record.Dates.Delete
+	}
+	if end.IsZero() {
+		return nil
+	}
+
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return max(calendarDays(start.In(loc), end.In(loc)), 0)
+}
+
+// calendarDays counts the day boundaries between from and to, both in the
+// same location
+func calendarDays(from time.Time, to time.Time) int {
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDay.Sub(fromDay).Hours() / 24)
+}
+
+// selectableColumns are the columns offered in the GUI: all available columns
+// plus the extra columns of the configured ones, with their default labels
+func selectableColumns(configured []column) []column {
+	columns := slices.Clone(availableColumns)
+	for _, col := range configured {
+		if strings.HasPrefix(col.Key, extraColumnPrefix) {
+			def, _ := lookupColumn(col.Key)
+			columns = append(columns, def)
+		}
+	}
+	return columns
+}
+
+// columnsByDefaultLabel resolves the default labels shown in the GUI to
+// columns in the given order. Columns also found in configured keep the
+// label configured there.
+func columnsByDefaultLabel(labels []string, configured []column) ([]column, error) {
+	selectable := selectableColumns(configured)
+
+	var columns []column
+	for _, label := range labels {
+		index := slices.IndexFunc(selectable, func(col column) bool { return col.Label == label })
+		if index < 0 {
+			return nil, fmt.Errorf("unknown column %q", label)
+		}
+		col := selectable[index]
+		if custom := slices.IndexFunc(configured, func(c column) bool { return c.Key == col.Key }); custom >= 0 {
+			col.Label = configured[custom].Label
+		}
+		columns = append(columns, col)
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("no columns selected")
+	}
+	return columns, nil
+}
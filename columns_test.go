@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		name           string
+		spec           string
+		expectedKeys   []string
+		expectedLabels []string
+		expectError    bool
+	}{
+		{
+			name:           "empty spec selects the defaults",
+			spec:           "",
+			expectedKeys:   []string{"name", "order_date", "registration_date", "delete_date"},
+			expectedLabels: []string{"Domain", "Order Date", "Reg Date", "Close Date"},
+		},
+		{
+			name:           "any order",
+			spec:           "tld,name,order_status",
+			expectedKeys:   []string{"tld", "name", "order_status"},
+			expectedLabels: []string{"TLD", "Domain", "Order Status"},
+		},
+		{
+			name:           "custom labels and spaces",
+			spec:           " name = Domainname , days_in_portfolio=Tage,SLD",
+			expectedKeys:   []string{"name", "days_in_portfolio", "sld"},
+			expectedLabels: []string{"Domainname", "Tage", "SLD"},
+		},
+		{
+			name:           "extra fields keep their case",
+			spec:           "name,Extra.authCode,extra.owner=Inhaber",
+			expectedKeys:   []string{"name", "extra.authCode", "extra.owner"},
+			expectedLabels: []string{"Domain", "authCode", "Inhaber"},
+		},
+		{
+			name:        "extra without field name",
+			spec:        "extra.",
+			expectError: true,
+		},
+		{
+			name:        "unknown column",
+			spec:        "name,price",
+			expectError: true,
+		},
+		{
+			name:        "empty entry",
+			spec:        "name,,tld",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, err := parseColumns(tt.spec)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var keys []string
+			for _, col := range columns {
+				keys = append(keys, col.Key)
+			}
+			assert.Equal(t, tt.expectedKeys, keys)
+			assert.Equal(t, tt.expectedLabels, columnLabels(columns))
+		})
+	}
+}
+
+func TestParseColumns_ErrorListsColumns(t *testing.T) {
+	_, err := parseColumns("price")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown column "price"`)
+	assert.Contains(t, err.Error(), "days_in_portfolio")
+}
+
+func TestFormatColumnSpec(t *testing.T) {
+	spec := "tld,name=Domainname,order_date"
+	columns, err := parseColumns(spec)
+	require.NoError(t, err)
+	assert.Equal(t, spec, formatColumnSpec(columns))
+
+	assert.Equal(t, defaultColumnSpec, formatColumnSpec(defaultColumns))
+}
+
+func TestExtraColumns(t *testing.T) {
+	var domain Domain
+	require.NoError(t, json.Unmarshal([]byte(`{"name":"example.com","auth_code_status":"locked","nameservers":["ns1.example.net", "ns2.example.net"],"renewal_days":30,"handle":null}`), &domain))
+	record := &exportRecord{Domain: domain}
+
+	columns, err := parseColumns("extra.auth_code_status,extra.nameservers,extra.renewal_days,extra.handle,extra.missing")
+	require.NoError(t, err)
+
+	var values []any
+	for _, col := range columns {
+		values = append(values, col.value(record, &exportOptions{}))
+	}
+	assert.Equal(t, []any{"locked", `["ns1.example.net","ns2.example.net"]`, "30", nil, nil}, values)
+}
+
+func TestColumnsByDefaultLabel(t *testing.T) {
+	configured, err := parseColumns("order_status=Status,name")
+	require.NoError(t, err)
+
+	columns, err := columnsByDefaultLabel([]string{"TLD", "Order Status", "Domain"}, configured)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TLD", "Status", "Domain"}, columnLabels(columns), "Configured labels should be kept")
+
+	configured, err = parseColumns("extra.handle=Handle")
+	require.NoError(t, err)
+	columns, err = columnsByDefaultLabel([]string{"Domain", "handle"}, configured)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Domain", "Handle"}, columnLabels(columns), "Configured extra columns should be selectable")
+
+	_, err = columnsByDefaultLabel([]string{"Price"}, nil)
+	assert.Error(t, err)
+	_, err = columnsByDefaultLabel(nil, nil)
+	assert.Error(t, err)
+}
+
+func TestColumnValues(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	cutoff, err := parseCutoffDate("2024-06-30", berlin)
+	require.NoError(t, err)
+	opts := exportOptions{CutoffDate: cutoff, Location: berlin}
+
+	records := testRecords(t)
+	columns, err := parseColumns("name,order_status,delete_date,delete_datetime,tld,sld,days_in_portfolio")
+	require.NoError(t, err)
+
+	var values []string
+	for _, col := range columns {
+		values = append(values, formatCell(col.value(records[1], &opts), opts.Location))
+	}
+	assert.Equal(t, []string{"müller.de", "deleted", "2024-01-01", "2023-12-31T23:30:00Z", "de", "müller", "729"}, values)
+}
+
+func TestDaysInPortfolio(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	cutoff, err := parseCutoffDate("2024-01-31", berlin)
+	require.NoError(t, err)
+
+	record := func(order string, registration string, deleted string) *exportRecord {
+		domain := Domain{Name: "example.de", OrderDateTime: order, RegistrationDateTime: registration, DeleteDateTime: deleted}
+		dates, errs := domain.parseDates()
+		require.Empty(t, errs)
+		return &exportRecord{Domain: domain, Dates: dates}
+	}
+
+	tests := []struct {
+		name     string
+		record   *exportRecord
+		opts     exportOptions
+		expected any
+	}{
+		{"active until the cutoff day", record("", "2024-01-01T10:00:00Z", ""), exportOptions{CutoffDate: cutoff, Location: berlin}, 30},
+		{"deleted before the cutoff", record("", "2024-01-01T10:00:00Z", "2024-01-11T10:00:00Z"), exportOptions{CutoffDate: cutoff, Location: berlin}, 10},
+		{"deleted after the cutoff", record("", "2024-01-01T10:00:00Z", "2024-03-01T10:00:00Z"), exportOptions{CutoffDate: cutoff, Location: berlin}, 30},
+		{"order date without registration", record("2023-12-31T10:00:00Z", "", ""), exportOptions{CutoffDate: cutoff, Location: berlin}, 31},
+		{"calendar days in the export time zone", record("", "2023-12-31T23:30:00Z", "2024-01-01T22:30:00Z"), exportOptions{CutoffDate: cutoff, Location: berlin}, 0},
+		{"calendar days in UTC", record("", "2023-12-31T23:30:00Z", "2024-01-01T22:30:00Z"), exportOptions{CutoffDate: cutoff}, 1},
+		{"no start date", record("", "", ""), exportOptions{CutoffDate: cutoff}, nil},
+		{"no cutoff and not deleted", record("", "2024-01-01T10:00:00Z", ""), exportOptions{}, nil},
+		{"registered after the cutoff", record("", "2024-02-05T10:00:00Z", ""), exportOptions{CutoffDate: cutoff, Location: berlin}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, daysInPortfolio(tt.record, &tt.opts))
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configDirName is the directory of this tool below the user config directory
+const configDirName = "nicmanager-export"
+
+// fileConfig is the content of the config file, a TOML file like
+//
+//	columns = ["name", "tld", "order_status=Status", "order_date"]
+//	time_zone = "Europe/Berlin"
+//
+//	[csv]
+//	preset = "de-DE Excel"
+//	date_format = "DD.MM.YY"
+//
+//	[filter]
+//	tld = ["com", "net"]
+//	status = ["active"]
+//	registered = "2019-01-01.."
+//
+//	[snapshots]
+//	keep = 24
+//
+//	[credentials]
+//	store = "keyring"
+//
+//	[profiles.customer-a]
+//	login = "customer-a.export"
+//	password_command = "pass show nicmanager/customer-a"
+//	output_dir = "~/Exports/customer-a"
+//	format = "xlsx"
+//	mode = "as-of"
+//
+//	[profiles.customer-a.filter]
+//	tld = ["de"]
+//
+// Settings given on the command line or in the GUI take precedence, see
+// profile for the order.
+type fileConfig struct {
+	profileSettings
+	// Snapshots configures the store of fetched domain lists
+	Snapshots snapshotSettings `toml:"snapshots"`
+	// Credentials configures where passwords are stored
+	Credentials credentialSettings `toml:"credentials"`
+	// Profiles are named settings applied on top of the top-level ones
+	Profiles map[string]profileSettings `toml:"profiles"`
+}
+
+// profileSettings are the export settings of the config file, at the top
+// level and in every profile. Empty fields keep the value of the level
+// below.
+type profileSettings struct {
+	// Login is the API user, the password is never part of the config
+	Login string `toml:"login"`
+	// PasswordFile, PasswordCommand and PasswordEnv tell where the password
+	// is read from, see passwordSource
+	PasswordFile    string `toml:"password_file"`
+	PasswordCommand string `toml:"password_command"`
+	PasswordEnv     string `toml:"password_env"`
+	// OutputDir is where relative output files are written, a leading ~ is
+	// the home directory
+	OutputDir string `toml:"output_dir"`
+	// Format is an output format, see parseOutputFormat
+	Format string `toml:"format"`
+	// TimeZone is an IANA time zone like Europe/Berlin
+	TimeZone string `toml:"time_zone"`
+	// Mode is an inventory mode, see parseInventoryMode
+	Mode string `toml:"mode"`
+	// Columns is a column spec with one column per entry, see parseColumns
+	Columns []string `toml:"columns"`
+	// CSV is the dialect of the CSV output
+	CSV csvSettings `toml:"csv"`
+	// Filter selects the exported domains
+	Filter filterSettings `toml:"filter"`
+}
+
+// profileEnvVar selects the profile when none is given on the command line
+const profileEnvVar = "NICMANAGER_PROFILE"
+
+// settingsFromEnv returns the settings overridden by environment variables
+func settingsFromEnv() profileSettings {
+	return profileSettings{
+		Login:     os.Getenv("NICMANAGER_LOGIN"),
+		OutputDir: os.Getenv("NICMANAGER_OUTPUT_DIR"),
+		Format:    os.Getenv("NICMANAGER_FORMAT"),
+		TimeZone:  os.Getenv("NICMANAGER_TIMEZONE"),
+		Mode:      os.Getenv("NICMANAGER_MODE"),
+		Columns:   splitList(os.Getenv("NICMANAGER_COLUMNS")),
+	}
+}
+
+// override returns s with every field that is set in other replaced
+func (s profileSettings) override(other profileSettings) profileSettings {
+	for _, field := range []struct{ value, other *string }{
+		{&s.Login, &other.Login},
+		{&s.PasswordFile, &other.PasswordFile},
+		{&s.PasswordCommand, &other.PasswordCommand},
+		{&s.PasswordEnv, &other.PasswordEnv},
+		{&s.OutputDir, &other.OutputDir},
+		{&s.Format, &other.Format},
+		{&s.TimeZone, &other.TimeZone},
+		{&s.Mode, &other.Mode},
+	} {
+		if *field.other != "" {
+			*field.value = *field.other
+		}
+	}
+	if len(other.Columns) > 0 {
+		s.Columns = other.Columns
+	}
+	s.CSV = s.CSV.override(other.CSV)
+	s.Filter = s.Filter.override(other.Filter)
+	return s
+}
+
+// profileNames returns the names of the profiles, sorted
+func (c *fileConfig) profileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
+// profile returns the settings of the named profile: the top-level settings,
+// overridden by the profile, overridden by the environment variables of
+// settingsFromEnv. An empty name selects $NICMANAGER_PROFILE, if that is
+// empty too only the top-level settings apply.
+func (c *fileConfig) profile(name string) (profileSettings, error) {
+	if name == "" {
+		name = os.Getenv(profileEnvVar)
+	}
+	settings := c.profileSettings
+	if name != "" {
+		selected, ok := c.Profiles[name]
+		if !ok {
+			return settings, fmt.Errorf("config file: unknown profile %q, available are %s", name, strings.Join(c.profileNames(), ", "))
+		}
+		settings = settings.override(selected)
+	}
+	return settings.override(settingsFromEnv()), nil
+}
+
+// passwordSource returns where the password of the profile is read from
+func (s *profileSettings) passwordSource() passwordSource {
+	return passwordSource{File: s.PasswordFile, Command: s.PasswordCommand, Env: cmp.Or(s.PasswordEnv, passwordEnvVar)}
+}
+
+// outputPath places a relative output file in OutputDir
+func (s *profileSettings) outputPath(name string) (string, error) {
+	if s.OutputDir == "" || filepath.IsAbs(name) {
+		return name, nil
+	}
+	dir := s.OutputDir
+	if rest, ok := strings.CutPrefix(dir, "~"); ok && (rest == "" || os.IsPathSeparator(rest[0])) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("output directory %s: %w", dir, err)
+		}
+		dir = filepath.Join(home, rest)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// defaultConfigPath returns the path of the config file in the user config
+// directory, e.g. ~/.config/nicmanager-export/config.toml on Linux
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configDirName, "config.toml"), nil
+}
+
+// loadConfig reads the config file at path, or the default config file if
+// path is empty. A missing default config file is no error, the tool works
+// without one.
+func loadConfig(path string) (fileConfig, error) {
+	var config fileConfig
+
+	explicit := path != ""
+	if !explicit {
+		var err error
+		if path, err = defaultConfigPath(); err != nil {
+			return config, nil
+		}
+	}
+
+	meta, err := toml.DecodeFile(path, &config)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	// typos should not silently fall back to defaults
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return config, fmt.Errorf("config file %s: unknown settings %s", path, strings.Join(keys, ", "))
+	}
+	return config, nil
+}
+
+// columns returns the columns selected in the config file, nil if none are
+func (s *profileSettings) columns() ([]column, error) {
+	if len(s.Columns) == 0 {
+		return nil, nil
+	}
+	columns, err := parseColumnList(s.Columns)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	return columns, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// isolateConfig points the user config and data directories to an empty
+// temporary directory, so tests do not pick up the config file or the
+// snapshots of the developer
+func isolateConfig(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XDG_DATA_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+	t.Setenv("LocalAppData", dir)
+	for _, name := range []string{profileEnvVar, "NICMANAGER_LOGIN", "NICMANAGER_OUTPUT_DIR", "NICMANAGER_FORMAT", "NICMANAGER_TIMEZONE", "NICMANAGER_MODE", "NICMANAGER_COLUMNS", passphraseEnvVar} {
+		t.Setenv(name, "")
+	}
+	return dir
+}
+
+// writeConfig writes a config file with content and returns its path
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `columns = ["name", "tld", "order_status=Status"]`)
+
+	config, err := loadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "tld", "order_status=Status"}, config.Columns)
+
+	columns, err := config.columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Domain", "TLD", "Status"}, columnLabels(columns))
+}
+
+func TestLoadConfig_CSV(t *testing.T) {
+	path := writeConfig(t, `
+[csv]
+preset = "de-DE Excel"
+date_format = "DD.MM.YY"
+bom = false
+`)
+
+	config, err := loadConfig(path)
+	require.NoError(t, err)
+
+	dialect, err := config.CSV.dialect()
+	require.NoError(t, err)
+	assert.Equal(t, csvDialect{Delimiter: ';', CRLF: true, DateLayout: "02.01.06"}, dialect)
+}
+
+func TestLoadConfig_Filter(t *testing.T) {
+	path := writeConfig(t, `
+[filter]
+tld = ["com", "net"]
+status = ["active"]
+registered = "2019-01-01.."
+`)
+
+	config, err := loadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, filterSettings{TLDs: []string{"com", "net"}, Statuses: []string{"active"}, Registered: "2019-01-01.."}, config.Filter)
+
+	filter, err := config.Filter.compile(nil)
+	require.NoError(t, err)
+	assert.NotNil(t, filter)
+}
+
+func TestLoadConfig_DefaultPath(t *testing.T) {
+	isolateConfig(t)
+
+	config, err := loadConfig("")
+	require.NoError(t, err, "A missing default config file is no error")
+	columns, err := config.columns()
+	require.NoError(t, err)
+	assert.Nil(t, columns, "Without config file the default columns should be used")
+
+	path, err := defaultConfigPath()
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte(`columns = ["tld"]`), 0o600))
+
+	config, err = loadConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tld"}, config.Columns)
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"missing explicit file", filepath.Join(t.TempDir(), "missing.toml"), "missing.toml"},
+		{"invalid TOML", writeConfig(t, `columns = [`), "config file"},
+		{"unknown setting", writeConfig(t, "colums = [\"name\"]\n"), "unknown settings colums"},
+		{"password", writeConfig(t, "password = \"secret\"\n"), "unknown settings password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(tt.path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestFileConfig_UnknownColumn(t *testing.T) {
+	config := fileConfig{profileSettings: profileSettings{Columns: []string{"name", "price"}}}
+	_, err := config.columns()
+	assert.ErrorContains(t, err, `config file: unknown column "price"`)
+}
+
+const profileConfig = `
+login = "default.user"
+time_zone = "Europe/Berlin"
+columns = ["name", "order_date"]
+
+[csv]
+preset = "de-DE Excel"
+
+[profiles.customer-a]
+login = "customer-a.export"
+output_dir = "~/Exports/customer-a"
+format = "xlsx"
+mode = "as-of"
+
+[profiles.customer-a.csv]
+date_format = "DD.MM.YY"
+
+[profiles.customer-a.filter]
+tld = ["de"]
+
+[profiles.customer-b]
+time_zone = "UTC"
+`
+
+func TestFileConfig_Profile(t *testing.T) {
+	isolateConfig(t)
+	config, err := loadConfig(writeConfig(t, profileConfig))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"customer-a", "customer-b"}, config.profileNames())
+
+	settings, err := config.profile("")
+	require.NoError(t, err)
+	assert.Equal(t, "default.user", settings.Login, "Without profile the top-level settings apply")
+
+	settings, err = config.profile("customer-a")
+	require.NoError(t, err)
+	assert.Equal(t, profileSettings{
+		Login:     "customer-a.export",
+		OutputDir: "~/Exports/customer-a",
+		Format:    "xlsx",
+		TimeZone:  "Europe/Berlin",
+		Mode:      "as-of",
+		Columns:   []string{"name", "order_date"},
+		CSV:       csvSettings{Preset: "de-DE Excel", DateFormat: "DD.MM.YY"},
+		Filter:    filterSettings{TLDs: []string{"de"}},
+	}, settings, "The profile should override the top-level settings field by field")
+
+	_, err = config.profile("customer-c")
+	assert.ErrorContains(t, err, `unknown profile "customer-c", available are customer-a, customer-b`)
+}
+
+func TestFileConfig_ProfileEnvironment(t *testing.T) {
+	isolateConfig(t)
+	config, err := loadConfig(writeConfig(t, profileConfig))
+	require.NoError(t, err)
+
+	t.Setenv(profileEnvVar, "customer-b")
+	t.Setenv("NICMANAGER_LOGIN", "env.user")
+	t.Setenv("NICMANAGER_COLUMNS", "tld, name")
+
+	settings, err := config.profile("")
+	require.NoError(t, err)
+	assert.Equal(t, "UTC", settings.TimeZone, "$NICMANAGER_PROFILE should select the profile")
+	assert.Equal(t, "env.user", settings.Login, "Environment variables should override the profile")
+	assert.Equal(t, []string{"tld", "name"}, settings.Columns)
+
+	settings, err = config.profile("customer-a")
+	require.NoError(t, err)
+	assert.Equal(t, "xlsx", settings.Format, "An explicit profile should win over $NICMANAGER_PROFILE")
+	assert.Equal(t, "env.user", settings.Login)
+}
+
+func TestProfileSettings_OutputPath(t *testing.T) {
+	home := isolateConfig(t)
+
+	settings := profileSettings{OutputDir: "~/Exports"}
+	path, err := settings.outputPath("export.csv")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "Exports", "export.csv"), path)
+
+	absolute := filepath.Join(t.TempDir(), "export.csv")
+	path, err = settings.outputPath(absolute)
+	require.NoError(t, err)
+	assert.Equal(t, absolute, path, "Absolute paths should ignore the output directory")
+
+	path, err = (&profileSettings{}).outputPath("export.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "export.csv", path)
+}
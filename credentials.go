@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// credentialService names this tool in the OS keyring
+const credentialService = "nicmanager-export"
+
+// passphraseEnvVar holds the passphrase of the encrypted credential file
+const passphraseEnvVar = "NICMANAGER_STORE_PASSPHRASE"
+
+// kinds of credential stores in the config file
+const (
+	storeKeyring = "keyring"
+	storeFile    = "file"
+	storeNone    = "none"
+)
+
+// errNoCredential is returned by lookup for a login without stored password
+var errNoCredential = errors.New("no stored password")
+
+// errKeyringUnavailable is returned on systems without a supported keyring
+var errKeyringUnavailable = errors.New("no OS keyring available")
+
+// credentialStore keeps API passwords by login, outside of the config file
+type credentialStore interface {
+	// name describes the store for messages
+	name() string
+	lookup(login string) (string, error)
+	store(login string, password string) error
+	remove(login string) error
+}
+
+// credentialSettings are the [credentials] section of the config file
+type credentialSettings struct {
+	// Store is keyring, file or none; empty uses the OS keyring where
+	// available
+	Store string `toml:"store"`
+	// File is the encrypted file of the file store, empty means
+	// credentials.enc next to the default config file
+	File string `toml:"file"`
+	// KeyFile holds the secret of the file store instead of a passphrase
+	KeyFile string `toml:"key_file"`
+}
+
+// openCredentialStore returns the configured store, nil if there is none.
+// The file store is encrypted with the content of the key file, or else the
+// passphrase from $NICMANAGER_STORE_PASSPHRASE or from askPassphrase, which
+// may be nil; both are only read when the file is.
+func openCredentialStore(settings credentialSettings, askPassphrase func() (string, error)) (credentialStore, error) {
+	switch strings.ToLower(settings.Store) {
+	case "":
+		store, err := newKeyring()
+		if errors.Is(err, errKeyringUnavailable) {
+			return nil, nil
+		}
+		return store, err
+	case storeKeyring:
+		return newKeyring()
+	case storeNone:
+		return nil, nil
+	case storeFile:
+	default:
+		return nil, fmt.Errorf("unknown credential store %q, expected keyring, file or none", settings.Store)
+	}
+
+	path := settings.File
+	if path == "" {
+		configPath, err := defaultConfigPath()
+		if err != nil {
+			return nil, fmt.Errorf("credential file: %w", err)
+		}
+		path = filepath.Join(filepath.Dir(configPath), "credentials.enc")
+	}
+	return &fileCredentialStore{path: path, secret: func() ([]byte, error) {
+		if settings.KeyFile != "" {
+			key, err := os.ReadFile(settings.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("key file: %w", err)
+			}
+			return key, nil
+		}
+		if passphrase := os.Getenv(passphraseEnvVar); passphrase != "" {
+			return []byte(passphrase), nil
+		}
+		if askPassphrase == nil {
+			return nil, fmt.Errorf("the credential file needs a passphrase in $%s or a key_file", passphraseEnvVar)
+		}
+		passphrase, err := askPassphrase()
+		return []byte(passphrase), err
+	}}, nil
+}
+
+// fileCredentialStore keeps the passwords in a file encrypted with AES-256-GCM,
+// the key is derived from a passphrase or key file with scrypt
+type fileCredentialStore struct {
+	path   string
+	secret func() ([]byte, error)
+	// key is the derived key once the file was read or written, with the
+	// salt it was derived with
+	key  []byte
+	salt []byte
+}
+
+// encryptedCredentials is the content of the credential file, the data is
+// the encrypted JSON object of passwords by login
+type encryptedCredentials struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// credentialFileVersion identifies the format and parameters of the file,
+// it is also authenticated with the data
+const credentialFileVersion = 1
+
+func (s *fileCredentialStore) name() string {
+	return "encrypted file " + s.path
+}
+
+// deriveKey derives the encryption key for salt from the secret
+func (s *fileCredentialStore) deriveKey(salt []byte) ([]byte, error) {
+	if s.key != nil && bytes.Equal(s.salt, salt) {
+		return s.key, nil
+	}
+	secret, err := s.secret()
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("the passphrase of the credential file is empty")
+	}
+	key, err := scrypt.Key(secret, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	s.key, s.salt = key, salt
+	return key, nil
+}
+
+// load decrypts the passwords, a missing file is an empty store
+func (s *fileCredentialStore) load() (map[string]string, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file encryptedCredentials
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("credential file %s: %w", s.path, err)
+	}
+	if file.Version != credentialFileVersion {
+		return nil, fmt.Errorf("credential file %s: unsupported version %d", s.path, file.Version)
+	}
+	key, err := s.deriveKey(file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newCredentialCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, file.Nonce, file.Data, credentialAdditionalData())
+	if err != nil {
+		// forget the key, the next attempt asks again
+		s.key = nil
+		return nil, fmt.Errorf("credential file %s: wrong passphrase or damaged file", s.path)
+	}
+
+	passwords := make(map[string]string)
+	if err := json.Unmarshal(plain, &passwords); err != nil {
+		return nil, fmt.Errorf("credential file %s: %w", s.path, err)
+	}
+	return passwords, nil
+}
+
+// save encrypts the passwords with a new nonce and replaces the file, which
+// only the user can read
+func (s *fileCredentialStore) save(passwords map[string]string) error {
+	salt := s.salt
+	if s.key == nil {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+	}
+	key, err := s.deriveKey(salt)
+	if err != nil {
+		return err
+	}
+	aead, err := newCredentialCipher(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	plain, err := json.Marshal(passwords)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(encryptedCredentials{
+		Version: credentialFileVersion,
+		Salt:    salt,
+		Nonce:   nonce,
+		Data:    aead.Seal(nil, nonce, plain, credentialAdditionalData()),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	// a temporary file is created readable by the user only
+	file, err := createTempBeside(s.path)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+func (s *fileCredentialStore) lookup(login string) (string, error) {
+	passwords, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	password, ok := passwords[login]
+	if !ok {
+		return "", errNoCredential
+	}
+	return password, nil
+}
+
+func (s *fileCredentialStore) store(login string, password string) error {
+	passwords, err := s.load()
+	if err != nil {
+		return err
+	}
+	passwords[login] = password
+	return s.save(passwords)
+}
+
+func (s *fileCredentialStore) remove(login string) error {
+	passwords, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := passwords[login]; !ok {
+		return errNoCredential
+	}
+	delete(passwords, login)
+	return s.save(passwords)
+}
+
+// newCredentialCipher returns AES-256-GCM for key
+func newCredentialCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// credentialAdditionalData binds the encrypted data to the file format
+func credentialAdditionalData() []byte {
+	return fmt.Appendf(nil, "%s credentials v%d", credentialService, credentialFileVersion)
+}
+
+// passwordSource tells where the password is read from when it is not given
+// directly, tried in this order: File, the output of Command, the
+// environment variable Env
+type passwordSource struct {
+	File    string
+	Command string
+	Env     string
+}
+
+// password returns the password from the first source that is set, ok is
+// false if none is. Errors never contain the password.
+func (s passwordSource) password(ctx context.Context) (password string, ok bool, err error) {
+	switch {
+	case s.File != "":
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", true, fmt.Errorf("password file: %w", err)
+		}
+		password = firstLine(data)
+		if password == "" {
+			return "", true, fmt.Errorf("password file %s is empty", s.File)
+		}
+		return password, true, nil
+	case s.Command != "":
+		output, err := shellCommand(ctx, s.Command).Output()
+		if err != nil {
+			return "", true, fmt.Errorf("password command: %w", err)
+		}
+		password = firstLine(output)
+		if password == "" {
+			return "", true, errors.New("password command printed no password")
+		}
+		return password, true, nil
+	case s.Env != "":
+		password = os.Getenv(s.Env)
+		return password, password != "", nil
+	}
+	return "", false, nil
+}
+
+// firstLine returns the first line of data without line ending, password
+// files and commands usually end with a newline
+func firstLine(data []byte) string {
+	line, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSuffix(line, "\r")
+}
+
+// shellCommand runs command with the shell of the system, its stderr goes to
+// the stderr of this tool, e.g. for prompts of a password manager
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	return cmd
+}
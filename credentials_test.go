@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCredentialStore(t *testing.T) {
+	isolateConfig(t)
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	t.Setenv(passphraseEnvVar, "correct horse")
+
+	store, err := openCredentialStore(credentialSettings{Store: storeFile, File: path}, nil)
+	require.NoError(t, err)
+
+	_, err = store.lookup("account.user")
+	assert.ErrorIs(t, err, errNoCredential, "A missing file should be an empty store")
+
+	require.NoError(t, store.store("account.user", "secret"))
+	require.NoError(t, store.store("other.user", "other"))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "secret", "The password must not be stored in plain text")
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	// a new store reads the file again
+	store, err = openCredentialStore(credentialSettings{Store: storeFile, File: path}, nil)
+	require.NoError(t, err)
+	password, err := store.lookup("account.user")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", password)
+
+	require.NoError(t, store.remove("account.user"))
+	_, err = store.lookup("account.user")
+	assert.ErrorIs(t, err, errNoCredential)
+	assert.ErrorIs(t, store.remove("account.user"), errNoCredential)
+	password, err = store.lookup("other.user")
+	require.NoError(t, err)
+	assert.Equal(t, "other", password)
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		t.Setenv(passphraseEnvVar, "wrong")
+		store, err := openCredentialStore(credentialSettings{Store: storeFile, File: path}, nil)
+		require.NoError(t, err)
+		_, err = store.lookup("other.user")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "wrong passphrase")
+	})
+
+	t.Run("no passphrase", func(t *testing.T) {
+		t.Setenv(passphraseEnvVar, "")
+		store, err := openCredentialStore(credentialSettings{Store: storeFile, File: path}, nil)
+		require.NoError(t, err)
+		_, err = store.lookup("other.user")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), passphraseEnvVar)
+
+		asked := 0
+		store, err = openCredentialStore(credentialSettings{Store: storeFile, File: path}, func() (string, error) {
+			asked++
+			return "correct horse", nil
+		})
+		require.NoError(t, err)
+		_, err = store.lookup("other.user")
+		require.NoError(t, err)
+		_, err = store.lookup("other.user")
+		require.NoError(t, err)
+		assert.Equal(t, 1, asked, "The passphrase should be asked for once")
+	})
+}
+
+func TestFileCredentialStore_KeyFile(t *testing.T) {
+	isolateConfig(t)
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("random key material"), 0o600))
+	settings := credentialSettings{Store: storeFile, File: filepath.Join(dir, "credentials.enc"), KeyFile: keyFile}
+
+	store, err := openCredentialStore(settings, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.store("account.user", "secret"))
+
+	store, err = openCredentialStore(settings, nil)
+	require.NoError(t, err)
+	password, err := store.lookup("account.user")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", password)
+
+	settings.KeyFile = filepath.Join(dir, "missing")
+	store, err = openCredentialStore(settings, nil)
+	require.NoError(t, err)
+	_, err = store.lookup("account.user")
+	assert.ErrorContains(t, err, "key file")
+}
+
+func TestOpenCredentialStore(t *testing.T) {
+	store, err := openCredentialStore(credentialSettings{Store: storeNone}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, store)
+
+	_, err = openCredentialStore(credentialSettings{Store: "vault"}, nil)
+	assert.ErrorContains(t, err, `unknown credential store "vault"`)
+}
+
+func TestPasswordSource(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("from-file\r\nsecond line\n"), 0o600))
+	emptyFile := filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(emptyFile, nil, 0o600))
+	t.Setenv("TEST_NICMANAGER_PASSWORD", "from-env")
+
+	tests := []struct {
+		name     string
+		source   passwordSource
+		expected string
+		ok       bool
+		err      string
+		shell    bool
+	}{
+		{"nothing", passwordSource{}, "", false, "", false},
+		{"file", passwordSource{File: passwordFile, Env: "TEST_NICMANAGER_PASSWORD"}, "from-file", true, "", false},
+		{"missing file", passwordSource{File: filepath.Join(dir, "missing")}, "", true, "password file", false},
+		{"empty file", passwordSource{File: emptyFile}, "", true, "is empty", false},
+		{"command", passwordSource{Command: "echo from-command", Env: "TEST_NICMANAGER_PASSWORD"}, "from-command", true, "", true},
+		{"failing command", passwordSource{Command: "exit 3"}, "", true, "password command", true},
+		{"environment", passwordSource{Env: "TEST_NICMANAGER_PASSWORD"}, "from-env", true, "", false},
+		{"unset environment", passwordSource{Env: "TEST_NICMANAGER_UNSET"}, "", false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.shell && runtime.GOOS == "windows" {
+				t.Skip("the command is written for sh")
+			}
+			password, ok, err := tt.source.password(context.Background())
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, password)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
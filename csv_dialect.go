@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// csvDialect controls how the CSV writer formats its output
+type csvDialect struct {
+	Delimiter rune
+	// QuoteAll quotes every field, otherwise only fields that need it
+	QuoteAll bool
+	CRLF     bool
+	// BOM starts the file with a UTF-8 byte order mark, which Excel needs to
+	// detect the encoding
+	BOM bool
+	// DateLayout is a Go time layout for the date columns
+	DateLayout string
+}
+
+// defaultCSVDialect is the dialect the CSV export always had
+var defaultCSVDialect = csvDialect{Delimiter: ',', DateLayout: "2006-01-02"}
+
+// csvPresets are the named dialects, keyed by their normalized name
+var csvPresets = map[string]csvDialect{
+	"default":     defaultCSVDialect,
+	"rfc4180":     {Delimiter: ',', CRLF: true, DateLayout: "2006-01-02"},
+	"de-de-excel": {Delimiter: ';', CRLF: true, BOM: true, DateLayout: "02.01.2006"},
+	"en-us-excel": {Delimiter: ',', CRLF: true, BOM: true, DateLayout: "01/02/2006"},
+}
+
+// csvPresetNames lists the preset names as offered to the user
+var csvPresetNames = []string{"default", "RFC4180", "de-DE Excel", "en-US Excel"}
+
+// normalizePresetName makes "de-DE Excel", "de-de-excel" and "de_DE_Excel"
+// the same preset
+func normalizePresetName(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "-", "_", "-").Replace(strings.TrimSpace(name)))
+}
+
+// csvSettings are the user facing dialect settings of the config file and the
+// command line. Empty fields keep the value of the preset.
+type csvSettings struct {
+	Preset string `toml:"preset"`
+	// Delimiter is a single character or "tab"
+	Delimiter string `toml:"delimiter"`
+	// Quote is "minimal" or "all"
+	Quote string `toml:"quote"`
+	// LineEnding is "lf" or "crlf"
+	LineEnding string `toml:"line_ending"`
+	BOM        *bool  `toml:"bom"`
+	// DateFormat is a pattern like DD.MM.YYYY, see parseDateFormat
+	DateFormat string `toml:"date_format"`
+}
+
+// override returns s with every field that is set in other replaced
+func (s csvSettings) override(other csvSettings) csvSettings {
+	if other.Preset != "" {
+		s.Preset = other.Preset
+	}
+	if other.Delimiter != "" {
+		s.Delimiter = other.Delimiter
+	}
+	if other.Quote != "" {
+		s.Quote = other.Quote
+	}
+	if other.LineEnding != "" {
+		s.LineEnding = other.LineEnding
+	}
+	if other.BOM != nil {
+		s.BOM = other.BOM
+	}
+	if other.DateFormat != "" {
+		s.DateFormat = other.DateFormat
+	}
+	return s
+}
+
+// dialect resolves the settings to a dialect: the preset, default if none is
+// given, with the individual settings applied on top
+func (s csvSettings) dialect() (csvDialect, error) {
+	dialect := defaultCSVDialect
+	if s.Preset != "" {
+		preset, ok := csvPresets[normalizePresetName(s.Preset)]
+		if !ok {
+			return dialect, fmt.Errorf("unknown CSV preset %q, available presets: %s", s.Preset, strings.Join(csvPresetNames, ", "))
+		}
+		dialect = preset
+	}
+
+	switch {
+	case s.Delimiter == "":
+	case strings.EqualFold(s.Delimiter, "tab") || s.Delimiter == `\t`:
+		dialect.Delimiter = '\t'
+	default:
+		delimiter, size := utf8.DecodeRuneInString(s.Delimiter)
+		if size != len(s.Delimiter) || !validDelimiter(delimiter) {
+			return dialect, fmt.Errorf("invalid CSV delimiter %q, expected a single character", s.Delimiter)
+		}
+		dialect.Delimiter = delimiter
+	}
+
+	switch strings.ToLower(s.Quote) {
+	case "":
+	case "minimal":
+		dialect.QuoteAll = false
+	case "all":
+		dialect.QuoteAll = true
+	default:
+		return dialect, fmt.Errorf("invalid CSV quoting %q, expected minimal or all", s.Quote)
+	}
+
+	switch strings.ToLower(s.LineEnding) {
+	case "":
+	case "lf":
+		dialect.CRLF = false
+	case "crlf":
+		dialect.CRLF = true
+	default:
+		return dialect, fmt.Errorf("invalid CSV line ending %q, expected lf or crlf", s.LineEnding)
+	}
+
+	if s.BOM != nil {
+		dialect.BOM = *s.BOM
+	}
+
+	if s.DateFormat != "" {
+		layout, err := parseDateFormat(s.DateFormat)
+		if err != nil {
+			return dialect, err
+		}
+		dialect.DateLayout = layout
+	}
+	return dialect, nil
+}
+
+// validDelimiter rejects delimiters that would make the output ambiguous
+func validDelimiter(r rune) bool {
+	return r != '"' && r != '\r' && r != '\n' && r != utf8.RuneError
+}
+
+// parseDateFormat turns a date pattern made of YYYY, YY, MM, DD and the
+// separators . - / and space into a Go time layout. Anything else is
+// rejected, a digit like 2 would otherwise be a placeholder of the layout.
+func parseDateFormat(pattern string) (string, error) {
+	placeholders := []struct{ token, layout string }{{"YYYY", "2006"}, {"YY", "06"}, {"MM", "01"}, {"DD", "02"}}
+	invalid := fmt.Errorf("invalid date format %q, use YYYY, YY, MM, DD and the separators . - / or space", pattern)
+
+	var layout strings.Builder
+	used := make(map[string]int)
+	rest := strings.ToUpper(pattern)
+	for rest != "" {
+		if strings.ContainsRune(".-/ ", rune(rest[0])) {
+			layout.WriteByte(rest[0])
+			rest = rest[1:]
+			continue
+		}
+		found := false
+		for _, p := range placeholders {
+			if after, ok := strings.CutPrefix(rest, p.token); ok {
+				layout.WriteString(p.layout)
+				used[p.token[:2]]++
+				rest, found = after, true
+				break
+			}
+		}
+		if !found {
+			return "", invalid
+		}
+	}
+	if used["MM"] != 1 || used["DD"] != 1 || used["YY"] > 1 {
+		return "", fmt.Errorf("invalid date format %q, it needs MM and DD once and at most one year", pattern)
+	}
+
+	// the layout must read back what it writes
+	now := time.Now()
+	if _, err := time.Parse(layout.String(), now.Format(layout.String())); err != nil {
+		return "", invalid
+	}
+	return layout.String(), nil
+}
+
+// csvRecordWriter writes CSV records in a dialect. encoding/csv only knows
+// minimal quoting, which is why this is not a csv.Writer.
+type csvRecordWriter struct {
+	out     *bufio.Writer
+	dialect csvDialect
+}
+
+func newCSVRecordWriter(out io.Writer, dialect csvDialect) *csvRecordWriter {
+	return &csvRecordWriter{out: bufio.NewWriter(out), dialect: dialect}
+}
+
+// writeBOM writes the byte order mark if the dialect asks for it
+func (w *csvRecordWriter) writeBOM() error {
+	if !w.dialect.BOM {
+		return nil
+	}
+	_, err := w.out.WriteString("\ufeff")
+	return err
+}
+
+func (w *csvRecordWriter) write(record []string) error {
+	for i, field := range record {
+		if i > 0 {
+			w.out.WriteRune(w.dialect.Delimiter)
+		}
+		if !w.dialect.QuoteAll && !w.needsQuotes(field) {
+			w.out.WriteString(field)
+			continue
+		}
+		w.out.WriteByte('"')
+		w.out.WriteString(strings.ReplaceAll(field, `"`, `""`))
+		w.out.WriteByte('"')
+	}
+
+	lineEnding := "\n"
+	if w.dialect.CRLF {
+		lineEnding = "\r\n"
+	}
+	// bufio.Writer keeps the first error, so checking the last write is enough
+	_, err := w.out.WriteString(lineEnding)
+	return err
+}
+
+// needsQuotes reports whether field has to be quoted, following the rules of
+// encoding/csv
+func (w *csvRecordWriter) needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if field == `\.` || strings.ContainsRune(field, w.dialect.Delimiter) || strings.ContainsAny(field, "\"\r\n") {
+		return true
+	}
+	return field[0] == ' ' || field[0] == '\t'
+}
+
+func (w *csvRecordWriter) flush() error {
+	return w.out.Flush()
+}
+
+// formatCSVCell formats a column value for the CSV output, dates in the
+// layout of the dialect
+func formatCSVCell(value any, loc *time.Location, dateLayout string) string {
+	date, ok := value.(time.Time)
+	if !ok {
+		return formatCell(value, loc)
+	}
+	if date.IsZero() {
+		return ""
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	return date.In(loc).Format(dateLayout)
+}
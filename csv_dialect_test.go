@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVSettings_Dialect(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name        string
+		settings    csvSettings
+		expected    csvDialect
+		expectError bool
+	}{
+		{"empty is the default", csvSettings{}, defaultCSVDialect, false},
+		{"preset", csvSettings{Preset: "de-DE Excel"}, csvDialect{Delimiter: ';', CRLF: true, BOM: true, DateLayout: "02.01.2006"}, false},
+		{"preset name variants", csvSettings{Preset: "DE_de-excel"}, csvPresets["de-de-excel"], false},
+		{"settings override the preset", csvSettings{Preset: "de-DE Excel", Delimiter: "tab", Quote: "all", LineEnding: "lf", BOM: &no, DateFormat: "yyyy/mm/dd"}, csvDialect{Delimiter: '\t', QuoteAll: true, DateLayout: "2006/01/02"}, false},
+		{"settings without preset", csvSettings{Delimiter: "|", LineEnding: "CRLF", BOM: &yes}, csvDialect{Delimiter: '|', CRLF: true, BOM: true, DateLayout: "2006-01-02"}, false},
+		{"multibyte delimiter", csvSettings{Delimiter: "§"}, csvDialect{Delimiter: '§', DateLayout: "2006-01-02"}, false},
+		{"unknown preset", csvSettings{Preset: "fr-FR Excel"}, csvDialect{}, true},
+		{"two character delimiter", csvSettings{Delimiter: ";;"}, csvDialect{}, true},
+		{"quote as delimiter", csvSettings{Delimiter: `"`}, csvDialect{}, true},
+		{"unknown quoting", csvSettings{Quote: "none"}, csvDialect{}, true},
+		{"unknown line ending", csvSettings{LineEnding: "cr"}, csvDialect{}, true},
+		{"invalid date format", csvSettings{DateFormat: "DD.MM.YYYY hh:mm"}, csvDialect{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialect, err := tt.settings.dialect()
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, dialect)
+		})
+	}
+}
+
+func TestCSVSettings_Override(t *testing.T) {
+	yes := true
+	config := csvSettings{Preset: "de-DE Excel", DateFormat: "DD.MM.YY", BOM: &yes}
+	flags := csvSettings{Preset: "RFC4180", Delimiter: ";"}
+
+	assert.Equal(t, csvSettings{Preset: "RFC4180", Delimiter: ";", DateFormat: "DD.MM.YY", BOM: &yes}, config.override(flags))
+	assert.Equal(t, config, config.override(csvSettings{}))
+}
+
+func TestParseDateFormat(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		expected    string
+		expectError bool
+	}{
+		{"YYYY-MM-DD", "2006-01-02", false},
+		{"DD.MM.YYYY", "02.01.2006", false},
+		{"dd.mm.yy", "02.01.06", false},
+		{"MM/DD/YYYY", "01/02/2006", false},
+		{"YYYY MM DD", "2006 01 02", false},
+		{"YYYY-MM", "", true},
+		{"DD.MM.YYYY hh:mm", "", true},
+		{"Jan 2, 2006", "", true},
+		{"2", "", true},
+		{"DD.MM.2006", "", true},
+		{"DD1MM", "", true},
+		{"DD.MM.DD", "", true},
+		{"YYYY.YY.MM.DD", "", true},
+		{"YYMMDD", "060102", false},
+	}
+
+	for _, tt := range tests {
+		layout, err := parseDateFormat(tt.pattern)
+		if tt.expectError {
+			assert.Error(t, err, "pattern %q", tt.pattern)
+			continue
+		}
+		require.NoError(t, err, "pattern %q", tt.pattern)
+		assert.Equal(t, tt.expected, layout)
+	}
+}
+
+// parseCSVOutput re-parses CSV output written in dialect
+func parseCSVOutput(t *testing.T, output []byte, dialect csvDialect) [][]string {
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.Comma = dialect.Delimiter
+	rows, err := reader.ReadAll()
+	require.NoError(t, err, "Output should be valid CSV: %q", output)
+	return rows
+}
+
+func TestCSVExportWriter_Dialects(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// labels that need quoting in every dialect
+	columns, err := parseColumnList([]string{"name=Domain; Name", `order_status=Status "Order"`, "order_date", "delete_date=Close, Date"})
+	require.NoError(t, err)
+
+	for _, name := range csvPresetNames {
+		dialect, err := csvSettings{Preset: name}.dialect()
+		require.NoError(t, err)
+
+		for _, quoteAll := range []bool{false, true} {
+			dialect.QuoteAll = quoteAll
+
+			output := writeAll(t, formatCSV, exportOptions{Location: berlin, Columns: columns, CSV: dialect}, testRecords(t))
+
+			bom := bytes.HasPrefix(output, []byte("\ufeff"))
+			assert.Equal(t, dialect.BOM, bom, "preset %s: BOM", name)
+			output = bytes.TrimPrefix(output, []byte("\ufeff"))
+
+			if dialect.CRLF {
+				assert.Equal(t, 3, bytes.Count(output, []byte("\r\n")), "preset %s: every line should end with CRLF", name)
+			} else {
+				assert.NotContains(t, string(output), "\r", "preset %s: lines should end with LF", name)
+			}
+			if quoteAll {
+				assert.True(t, bytes.HasPrefix(output, []byte(`"Domain; Name"`+string(dialect.Delimiter)+`"Status ""Order"""`)), "preset %s: all fields should be quoted: %s", name, output)
+			}
+
+			rows := parseCSVOutput(t, output, dialect)
+			require.Len(t, rows, 3, "preset %s", name)
+			assert.Equal(t, []string{"Domain; Name", `Status "Order"`, "Order Date", "Close, Date"}, rows[0], "preset %s", name)
+			assert.Equal(t, []string{"müller.de", "deleted"}, rows[2][:2], "preset %s", name)
+
+			closeDate, err := time.ParseInLocation(dialect.DateLayout, rows[2][3], berlin)
+			require.NoError(t, err, "preset %s: date should follow the layout", name)
+			assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, berlin), closeDate, "preset %s", name)
+			assert.Equal(t, "", rows[1][3], "preset %s: missing dates stay empty", name)
+		}
+	}
+}
+
+func TestCSVExportWriter_GermanExcel(t *testing.T) {
+	dialect, err := csvSettings{Preset: "de-DE Excel"}.dialect()
+	require.NoError(t, err)
+
+	output := writeAll(t, formatCSV, exportOptions{CSV: dialect}, testRecords(t))
+
+	assert.Equal(t, "\ufeffDomain;Order Date;Reg Date;Close Date\r\n"+
+		"example.com;01.01.2023;02.01.2023;\r\n"+
+		"müller.de;01.01.2022;02.01.2022;31.12.2023\r\n", string(output))
+}
+
+func TestCSVRecordWriter_MinimalQuoting(t *testing.T) {
+	var out bytes.Buffer
+	writer := newCSVRecordWriter(&out, defaultCSVDialect)
+	require.NoError(t, writer.write([]string{"plain", "", " leading space", "line\nbreak", `\.`, `say "hi"`}))
+	require.NoError(t, writer.flush())
+
+	// the same rules as encoding/csv
+	var expected bytes.Buffer
+	stdlib := csv.NewWriter(&expected)
+	require.NoError(t, stdlib.Write([]string{"plain", "", " leading space", "line\nbreak", `\.`, `say "hi"`}))
+	stdlib.Flush()
+
+	assert.Equal(t, expected.String(), out.String())
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// kinds of domainChange
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeChanged = "changed"
+)
+
+// fieldChange is a compared field that differs between two snapshots, dates
+// as calendar days
+type fieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// domainChange is a domain that was added, removed or changed between two
+// snapshots
+type domainChange struct {
+	Name   string        `json:"name"`
+	Change string        `json:"change"`
+	Fields []fieldChange `json:"fields,omitempty"`
+}
+
+// diffReport lists the changes between two snapshots, sorted by domain name
+type diffReport struct {
+	Changes []domainChange
+}
+
+// counts returns the number of added, removed and changed domains
+func (r *diffReport) counts() (added int, removed int, changed int) {
+	for _, change := range r.Changes {
+		switch change.Change {
+		case changeAdded:
+			added++
+		case changeRemoved:
+			removed++
+		default:
+			changed++
+		}
+	}
+	return added, removed, changed
+}
+
+// diffSnapshots compares the domains of the snapshots before and after by
+// name, case insensitively. Dates are compared as calendar days in loc, as
+// tabular exports contain nothing more precise, and only fields both
+// snapshots contain are compared.
+func diffSnapshots(before snapshot, after snapshot, loc *time.Location) diffReport {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	oldByName := make(map[string]*Domain, len(before.Domains))
+	for i := range before.Domains {
+		oldByName[strings.ToLower(before.Domains[i].Name)] = &before.Domains[i]
+	}
+
+	var fields []string
+	for _, field := range diffFields {
+		if before.Fields[field] && after.Fields[field] {
+			fields = append(fields, field)
+		}
+	}
+
+	var report diffReport
+	seen := make(map[string]bool, len(after.Domains))
+	for i := range after.Domains {
+		domain := &after.Domains[i]
+		key := strings.ToLower(domain.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		previous, ok := oldByName[key]
+		if !ok {
+			report.Changes = append(report.Changes, domainChange{Name: domain.Name, Change: changeAdded})
+			continue
+		}
+
+		oldValues, newValues := diffValues(previous, loc), diffValues(domain, loc)
+		var changes []fieldChange
+		for _, field := range fields {
+			if oldValues[field] != newValues[field] {
+				changes = append(changes, fieldChange{Field: field, Old: oldValues[field], New: newValues[field]})
+			}
+		}
+		if len(changes) > 0 {
+			report.Changes = append(report.Changes, domainChange{Name: domain.Name, Change: changeChanged, Fields: changes})
+		}
+	}
+
+	for i := range before.Domains {
+		domain := &before.Domains[i]
+		key := strings.ToLower(domain.Name)
+		if !seen[key] {
+			seen[key] = true
+			report.Changes = append(report.Changes, domainChange{Name: domain.Name, Change: changeRemoved})
+		}
+	}
+
+	slices.SortFunc(report.Changes, func(a, b domainChange) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return report
+}
+
+// diffValues returns the compared fields of d, dates as calendar days in
+// loc; an unreadable date is compared by its raw value
+func diffValues(d *Domain, loc *time.Location) map[string]string {
+	values := map[string]string{fieldStatus: d.OrderStatus}
+	for field, raw := range map[string]string{
+		fieldOrder:        d.OrderDateTime,
+		fieldRegistration: d.RegistrationDateTime,
+		fieldDelete:       d.DeleteDateTime,
+	} {
+		if date, err := parseAPIdate(raw); err == nil {
+			values[field] = formatDate(date, loc)
+		} else {
+			values[field] = raw
+		}
+	}
+	return values
+}
+
+// diffRows flattens the report into table rows of change, domain, field,
+// old and new value: one row per changed field, added and removed domains
+// get a single row with empty field columns
+func diffRows(report diffReport) [][]string {
+	var rows [][]string
+	for _, change := range report.Changes {
+		if len(change.Fields) == 0 {
+			rows = append(rows, []string{change.Change, change.Name, "", "", ""})
+			continue
+		}
+		for _, field := range change.Fields {
+			rows = append(rows, []string{change.Change, change.Name, field.Field, field.Old, field.New})
+		}
+	}
+	return rows
+}
+
+// writeDiffCSV writes the rows of the report, see diffRows
+func writeDiffCSV(out io.Writer, report diffReport, dialect csvDialect) error {
+	writer := newCSVRecordWriter(out, dialect)
+	if err := writer.writeBOM(); err != nil {
+		return err
+	}
+	if err := writer.write([]string{"Change", "Domain", "Field", "Old", "New"}); err != nil {
+		return err
+	}
+	for _, row := range diffRows(report) {
+		if err := writer.write(row); err != nil {
+			return err
+		}
+	}
+	return writer.flush()
+}
+
+// writeDiffJSON writes the report as an indented JSON array of changes
+func writeDiffJSON(out io.Writer, report diffReport) error {
+	changes := report.Changes
+	if changes == nil {
+		changes = []domainChange{}
+	}
+	buffered := bufio.NewWriter(out)
+	encoder := json.NewEncoder(buffered)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(changes); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// writeDiff writes the report as CSV or JSON
+func writeDiff(out io.Writer, report diffReport, format outputFormat, dialect csvDialect) error {
+	switch format {
+	case formatCSV:
+		return writeDiffCSV(out, report, dialect)
+	case formatJSON:
+		return writeDiffJSON(out, report)
+	default:
+		return fmt.Errorf("diff reports can be written as csv or json, not %s", format)
+	}
+}
+
+// writeDiffFile writes the report to path, see writeFileAtomic
+func writeDiffFile(path string, report diffReport, format outputFormat, dialect csvDialect) error {
+	_, err := writeFileAtomic(path, false, func(out io.Writer) error {
+		return writeDiff(out, report, format, dialect)
+	})
+	return err
+}
@@ -0,0 +1,148 @@
+//go:build !test && !nogui
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// diffColumnNames are the German headers of the diff table, in the order of
+// diffRows
+var diffColumnNames = []string{"Änderung", "Domain", "Feld", "Alt", "Neu"}
+
+// showDiffWindow opens the window to compare two exports, or an export with
+// the current inventory fetched with the credentials of the main form; the
+// columns and CSV format are those of the profile selected there, store
+// resolves snapshot:<id> references and may be nil
+func showDiffWindow(a fyne.App, settings profileSettings, store *snapshotStore, credentials func() (string, string), loc func() *time.Location) {
+	w := a.NewWindow("Exporte vergleichen")
+
+	uiOld := widget.NewEntry()
+	uiOld.SetPlaceHolder("Export_2024-01.csv oder snapshot:latest")
+	uiNew := widget.NewEntry()
+	uiNew.SetPlaceHolder("leer = aktueller Bestand")
+	uiReport := widget.NewEntry()
+	uiReport.SetPlaceHolder("Vergleich.csv")
+
+	summary := widget.NewLabel("")
+	progress := widget.NewProgressBarInfinite()
+	progress.Hide()
+
+	var report diffReport
+	var rows [][]string
+	table := widget.NewTable(
+		func() (int, int) { return len(rows) + 1, len(diffColumnNames) },
+		func() fyne.CanvasObject { return widget.NewLabel("registration_date") },
+		func(id widget.TableCellID, cell fyne.CanvasObject) {
+			label := cell.(*widget.Label)
+			if id.Row == 0 {
+				label.TextStyle = fyne.TextStyle{Bold: true}
+				label.SetText(diffColumnNames[id.Col])
+				return
+			}
+			label.TextStyle = fyne.TextStyle{}
+			label.SetText(rows[id.Row-1][id.Col])
+		},
+	)
+	table.SetColumnWidth(1, 200)
+
+	var uiSave *widget.Button
+	uiSave = widget.NewButton("Bericht speichern", func() {
+		format, ok := formatFromPath(uiReport.Text)
+		if !ok || (format != formatCSV && format != formatJSON) {
+			dialog.ShowError(errors.New("Der Bericht muss auf .csv oder .json enden"), w)
+			return
+		}
+		dialect, err := settings.CSV.dialect()
+		if err == nil {
+			err = writeDiffFile(uiReport.Text, report, format, dialect)
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("Bericht konnte nicht gespeichert werden: %w", err), w)
+			return
+		}
+		summary.SetText(fmt.Sprintf("%s, gespeichert in %s", diffSummary(report), uiReport.Text))
+	})
+	uiSave.Disable()
+
+	var uiCompare *widget.Button
+	uiCompare = widget.NewButton("Vergleichen", func() {
+		if uiOld.Text == "" {
+			dialog.ShowError(errors.New("Bitte den alten Export angeben"), w)
+			return
+		}
+		login, password := credentials()
+		if uiNew.Text == "" && (login == "" || password == "") {
+			dialog.ShowError(errors.New("Für den Vergleich mit dem aktuellen Bestand werden Benutzer und Passwort im Hauptfenster benötigt"), w)
+			return
+		}
+
+		columns, colErr := settings.columns()
+		dialect, dialectErr := settings.CSV.dialect()
+		if err := errors.Join(colErr, dialectErr); err != nil {
+			dialog.ShowError(fmt.Errorf("Konfigurationsdatei fehlerhaft: %w", err), w)
+			return
+		}
+		opts := readOptions{Location: loc(), DateLayout: dialect.DateLayout, Columns: columns, Store: store}
+		oldPath, newPath := uiOld.Text, uiNew.Text
+
+		uiCompare.Disable()
+		uiSave.Disable()
+		progress.Show()
+		go func() {
+			before, err := readSnapshot(oldPath, opts)
+			var after snapshot
+			if err == nil && newPath != "" {
+				after, err = readSnapshot(newPath, opts)
+			} else if err == nil {
+				after, err = fetchSnapshot(context.Background(), newAPIClient(login, password), opts.Location)
+			}
+
+			fyne.Do(func() {
+				progress.Hide()
+				uiCompare.Enable()
+				if err != nil {
+					log.Println(err)
+					dialog.ShowError(errors.New(userMessage(err)), w)
+					return
+				}
+				report = diffSnapshots(before, after, opts.Location)
+				rows = diffRows(report)
+				table.Refresh()
+				summary.SetText(diffSummary(report))
+				uiSave.Enable()
+			})
+		}()
+	})
+
+	form := widget.NewForm(
+		widget.NewFormItem("Alter Export", uiOld),
+		widget.NewFormItem("Neuer Export", uiNew),
+	)
+	bottom := container.NewVBox(
+		summary,
+		container.NewBorder(nil, nil, widget.NewLabel("Bericht"), uiSave, uiReport),
+	)
+	w.SetContent(container.NewBorder(
+		container.NewVBox(form, uiCompare, progress),
+		bottom, nil, nil,
+		table,
+	))
+	w.Resize(fyne.NewSize(700, 500))
+	w.Show()
+}
+
+// diffSummary counts the changes of report for the status line
+func diffSummary(report diffReport) string {
+	added, removed, changed := report.counts()
+	return fmt.Sprintf("%d hinzugekommen, %d entfernt, %d geändert", added, removed, changed)
+}
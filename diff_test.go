@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	before := snapshot{Fields: allFields, Domains: []Domain{
+		{Name: "kept.com", OrderStatus: "active", RegistrationDateTime: "2020-01-01T10:00:00Z"},
+		{Name: "removed.com", OrderStatus: "active"},
+		{Name: "Status.de", OrderStatus: "active"},
+		{Name: "deleted.net", OrderStatus: "active", RegistrationDateTime: "2020-01-01T10:00:00Z"},
+	}}
+	after := snapshot{Fields: allFields, Domains: []Domain{
+		{Name: "added.org", OrderStatus: "active"},
+		{Name: "kept.com", OrderStatus: "active", RegistrationDateTime: "2020-01-01T12:00:00Z"},
+		{Name: "status.de", OrderStatus: "transfer"},
+		{Name: "deleted.net", OrderStatus: "deleted", RegistrationDateTime: "2020-01-01T10:00:00Z", DeleteDateTime: "2024-02-01T00:00:00Z"},
+	}}
+
+	report := diffSnapshots(before, after, time.UTC)
+
+	assert.Equal(t, []domainChange{
+		{Name: "added.org", Change: changeAdded},
+		{Name: "deleted.net", Change: changeChanged, Fields: []fieldChange{
+			{Field: fieldStatus, Old: "active", New: "deleted"},
+			{Field: fieldDelete, Old: "", New: "2024-02-01"},
+		}},
+		{Name: "removed.com", Change: changeRemoved},
+		{Name: "status.de", Change: changeChanged, Fields: []fieldChange{
+			{Field: fieldStatus, Old: "active", New: "transfer"},
+		}},
+	}, report.Changes, "Names should match case insensitively, times within the same day are no change")
+
+	added, removed, changed := report.counts()
+	assert.Equal(t, []int{1, 1, 2}, []int{added, removed, changed})
+}
+
+func TestDiffSnapshots_CommonFieldsOnly(t *testing.T) {
+	// a CSV export with the default columns has no status
+	before := snapshot{Fields: map[string]bool{fieldOrder: true, fieldRegistration: true, fieldDelete: true}, Domains: []Domain{
+		{Name: "example.com", OrderDateTime: "2023-01-01T00:00:00+01:00"},
+	}}
+	after := snapshot{Fields: allFields, Domains: []Domain{
+		{Name: "example.com", OrderStatus: "active", OrderDateTime: "2022-12-31T23:00:00Z"},
+	}}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	assert.Empty(t, diffSnapshots(before, after, berlin).Changes, "Only fields of both snapshots should be compared, dates as days in the time zone")
+}
+
+func TestDiffSnapshots_UnreadableDate(t *testing.T) {
+	before := snapshot{Fields: allFields, Domains: []Domain{{Name: "example.com", DeleteDateTime: "soon"}}}
+	after := snapshot{Fields: allFields, Domains: []Domain{{Name: "example.com", DeleteDateTime: "2024-01-01T00:00:00Z"}}}
+
+	report := diffSnapshots(before, after, nil)
+	require.Len(t, report.Changes, 1)
+	assert.Equal(t, []fieldChange{{Field: fieldDelete, Old: "soon", New: "2024-01-01"}}, report.Changes[0].Fields)
+}
+
+// testDiffReport has one change of every kind
+var testDiffReport = diffReport{Changes: []domainChange{
+	{Name: "added.org", Change: changeAdded},
+	{Name: "deleted.net", Change: changeChanged, Fields: []fieldChange{
+		{Field: fieldStatus, Old: "active", New: "deleted"},
+		{Field: fieldDelete, Old: "", New: "2024-02-01"},
+	}},
+	{Name: "removed.com", Change: changeRemoved},
+}}
+
+func TestWriteDiff_CSV(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeDiff(&out, testDiffReport, formatCSV, defaultCSVDialect))
+
+	assert.Equal(t, "Change,Domain,Field,Old,New\n"+
+		"added,added.org,,,\n"+
+		"changed,deleted.net,order_status,active,deleted\n"+
+		"changed,deleted.net,delete_date,,2024-02-01\n"+
+		"removed,removed.com,,,\n", out.String())
+}
+
+func TestWriteDiff_JSON(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeDiff(&out, testDiffReport, formatJSON, defaultCSVDialect))
+
+	assert.JSONEq(t, `[
+		{"name": "added.org", "change": "added"},
+		{"name": "deleted.net", "change": "changed", "fields": [
+			{"field": "order_status", "old": "active", "new": "deleted"},
+			{"field": "delete_date", "old": "", "new": "2024-02-01"}
+		]},
+		{"name": "removed.com", "change": "removed"}
+	]`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeDiff(&out, diffReport{}, formatJSON, defaultCSVDialect))
+	assert.Equal(t, "[]\n", out.String(), "An empty report should still be a JSON array")
+}
+
+func TestWriteDiff_UnsupportedFormat(t *testing.T) {
+	var out bytes.Buffer
+	assert.Error(t, writeDiff(&out, testDiffReport, formatXLSX, defaultCSVDialect))
+}
@@ -1,28 +1,130 @@
 package main
 
 import (
+	"fmt"
+	"strings"
 	"time"
+	// embedded zone database, Windows has none the time package could use
+	_ "time/tzdata"
+
+	"github.com/mariow/nicmanager-export/nicmanager"
 )
 
-// Domain struct represents a domain entry from the API
-type Domain struct {
-	Name                 string `json:"name"`
-	OrderStatus          string `json:"order_status"`
-	OrderDateTime        string `json:"order_datetime"`
-	RegistrationDateTime string `json:"registration_datetime"`
-	DeleteDateTime       string `json:"delete_datetime"`
+// Domain is a domain entry from the API with the export specific logic attached
+type Domain nicmanager.Domain
+
+// UnmarshalJSON decodes like nicmanager.Domain, keeping unknown fields in Extra
+func (d *Domain) UnmarshalJSON(data []byte) error {
+	return (*nicmanager.Domain)(d).UnmarshalJSON(data)
+}
+
+// MarshalJSON encodes like nicmanager.Domain, including the Extra fields
+func (d Domain) MarshalJSON() ([]byte, error) {
+	return nicmanager.Domain(d).MarshalJSON()
+}
+
+// DateError describes a timestamp of a domain that could not be parsed
+type DateError struct {
+	Domain string
+	// Field is the JSON name of the timestamp
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *DateError) Error() string {
+	return fmt.Sprintf("domain %s: invalid %s %q: %v", e.Domain, e.Field, e.Value, e.Err)
+}
+
+func (e *DateError) Unwrap() error {
+	return e.Err
+}
+
+// domainDates are the parsed timestamps of a domain, empty API values stay
+// zero, as do values that could not be parsed
+type domainDates struct {
+	Order        time.Time
+	Registration time.Time
+	Delete       time.Time
 }
 
 // parseAPIdate parses date strings from the Nicmanager API
 func parseAPIdate(dateString string) (time.Time, error) {
-	return time.Parse("2006-01-02T15:04:05Z", dateString)
+	return nicmanager.ParseTime(dateString)
+}
+
+// parseDates parses all timestamps of d and returns a DateError for every
+// non-empty timestamp that could not be parsed
+func (d *Domain) parseDates() (domainDates, []*DateError) {
+	var dates domainDates
+	var errs []*DateError
+
+	for _, field := range []struct {
+		name   string
+		value  string
+		target *time.Time
+	}{
+		{"order_datetime", d.OrderDateTime, &dates.Order},
+		{"registration_datetime", d.RegistrationDateTime, &dates.Registration},
+		{"delete_datetime", d.DeleteDateTime, &dates.Delete},
+	} {
+		if field.value == "" {
+			continue
+		}
+		parsed, err := parseAPIdate(field.value)
+		if err != nil {
+			errs = append(errs, &DateError{Domain: d.Name, Field: field.name, Value: field.value, Err: err})
+			continue
+		}
+		*field.target = parsed
+	}
+
+	return dates, errs
+}
+
+// TLD returns the top level domain of d without the leading dot, or an empty
+// string if the name has no dot
+func (d *Domain) TLD() string {
+	dot := strings.LastIndexByte(d.Name, '.')
+	if dot < 0 {
+		return ""
+	}
+	return strings.ToLower(d.Name[dot+1:])
+}
+
+// SLD returns the label directly left of the TLD, e.g. "example" for
+// example.com, or an empty string if the name has no dot. Public suffixes
+// with more than one label are not taken into account.
+func (d *Domain) SLD() string {
+	dot := strings.LastIndexByte(d.Name, '.')
+	if dot < 0 {
+		return ""
+	}
+	rest := d.Name[:dot]
+	return strings.ToLower(rest[strings.LastIndexByte(rest, '.')+1:])
 }
 
-// IsBelowCutoff filters for records without delete date or with delete date after cutoff
+// parseCutoffDate parses a cutoff date in the form YYYY-MM-DD. The cutoff
+// covers the whole day in loc, so the returned instant is the last
+// nanosecond of that day, which also holds on 23 and 25 hour DST days.
+func parseCutoffDate(value string, loc *time.Location) (time.Time, error) {
+	day, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc).Add(-time.Nanosecond), nil
+}
+
+// IsBelowCutoff filters for records without delete date or with delete date after cutoff.
+// cutoffDate is the last instant that still belongs to the inventory, usually
+// the end of the cutoff day from parseCutoffDate: a domain deleted at or
+// before it is gone, one deleted later was still there.
+// A delete date that cannot be parsed keeps the record, dropping it would
+// silently lose a domain that may well still be in the inventory.
 func (d *Domain) IsBelowCutoff(cutoffDate time.Time) bool {
 	if d.DeleteDateTime != "" {
-		parseDelDate, _ := parseAPIdate(d.DeleteDateTime)
-		if parseDelDate.Unix() > cutoffDate.Unix() {
+		parseDelDate, err := parseAPIdate(d.DeleteDateTime)
+		if err != nil || parseDelDate.After(cutoffDate) {
 			return true
 		}
 	} else {
@@ -30,3 +132,44 @@ func (d *Domain) IsBelowCutoff(cutoffDate time.Time) bool {
 	}
 	return false
 }
+
+// startDate returns the date from which d belongs to the portfolio: the
+// registration date, or the order date for domains that have none yet.
+// ok is false if neither can be read.
+func (d *Domain) startDate() (start time.Time, ok bool) {
+	for _, value := range []string{d.RegistrationDateTime, d.OrderDateTime} {
+		if value == "" {
+			continue
+		}
+		if parsed, err := parseAPIdate(value); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// IsInPortfolioAt reports whether d was in the portfolio at date: registered
+// (or ordered) at or before it and not yet deleted, with the same delete date
+// rules as IsBelowCutoff. A domain whose start cannot be determined is kept,
+// like one with an unreadable delete date.
+func (d *Domain) IsInPortfolioAt(date time.Time) bool {
+	if !d.IsBelowCutoff(date) {
+		return false
+	}
+	start, ok := d.startDate()
+	return !ok || !start.After(date)
+}
+
+// IsActiveBetween reports whether d was in the portfolio at any instant from
+// from to to, both inclusive, in the sense of IsInPortfolioAt.
+func (d *Domain) IsActiveBetween(from time.Time, to time.Time) bool {
+	// the first instant of the range the domain existed at
+	first := from
+	if start, ok := d.startDate(); ok && start.After(from) {
+		first = start
+	}
+	if first.After(to) {
+		return false
+	}
+	return d.IsBelowCutoff(first)
+}
@@ -1,11 +1,6 @@
 package main
 
 import (
-	"errors"
-	"fmt"
-	"io"
-	"net/http"
-	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -38,6 +33,30 @@ func TestParseAPIdate(t *testing.T) {
 			expected:    time.Date(2022, 12, 31, 23, 59, 59, 0, time.UTC),
 			expectError: false,
 		},
+		{
+			name:        "fractional seconds",
+			dateString:  "2023-03-15T14:30:45.123456Z",
+			expected:    time.Date(2023, 3, 15, 14, 30, 45, 123456000, time.UTC),
+			expectError: false,
+		},
+		{
+			name:        "positive offset is converted to UTC",
+			dateString:  "2023-03-15T14:30:45+01:00",
+			expected:    time.Date(2023, 3, 15, 13, 30, 45, 0, time.UTC),
+			expectError: false,
+		},
+		{
+			name:        "negative offset with fractional seconds",
+			dateString:  "2023-12-31T23:30:00.5-02:00",
+			expected:    time.Date(2024, 1, 1, 1, 30, 0, 500000000, time.UTC),
+			expectError: false,
+		},
+		{
+			name:        "space instead of T",
+			dateString:  "2023-03-15 14:30:45Z",
+			expected:    time.Date(2023, 3, 15, 14, 30, 45, 0, time.UTC),
+			expectError: false,
+		},
 		{
 			name:        "invalid date format - missing Z",
 			dateString:  "2023-03-15T14:30:45",
@@ -140,6 +159,14 @@ func TestDomain_IsBelowCutoff(t *testing.T) {
 			},
 			expected: false,
 		},
+		{
+			name: "domain with unreadable delete date should be included",
+			domain: Domain{
+				Name:           "example.com",
+				DeleteDateTime: "31.05.2023",
+			},
+			expected: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -173,133 +200,275 @@ func TestDomain_IsBelowCutoff_EdgeCases(t *testing.T) {
 	})
 }
 
-// Benchmark tests to ensure performance is acceptable
-func BenchmarkParseAPIdate(b *testing.B) {
-	dateString := "2023-03-15T14:30:45Z"
-	for i := 0; i < b.N; i++ {
-		_, _ = parseAPIdate(dateString)
+func TestDomain_IsInPortfolioAt(t *testing.T) {
+	date := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		domain   Domain
+		expected bool
+	}{
+		{"no dates", Domain{}, true},
+		{"deleted after date", Domain{RegistrationDateTime: "2020-01-01T00:00:00Z", DeleteDateTime: "2023-07-15T10:30:00Z"}, true},
+		{"deleted on date", Domain{RegistrationDateTime: "2020-01-01T00:00:00Z", DeleteDateTime: "2023-06-01T00:00:00Z"}, false},
+		{"deleted before date", Domain{RegistrationDateTime: "2020-01-01T00:00:00Z", DeleteDateTime: "2023-05-15T10:30:00Z"}, false},
+		{"deleted just after date", Domain{DeleteDateTime: "2023-06-01T00:00:01Z"}, true},
+		{"deleted just before date", Domain{DeleteDateTime: "2023-05-31T23:59:59Z"}, false},
+		{"unreadable delete date", Domain{DeleteDateTime: "31.05.2023"}, true},
+		{"registered after date", Domain{RegistrationDateTime: "2023-07-15T10:30:00Z"}, false},
+		{"registered on date", Domain{RegistrationDateTime: "2023-06-01T00:00:00Z"}, true},
+		{"registered just after date", Domain{RegistrationDateTime: "2023-06-01T00:00:01Z"}, false},
+		{"registered just before date", Domain{RegistrationDateTime: "2023-05-31T23:59:59Z"}, true},
+		{"registration date wins over order date", Domain{OrderDateTime: "2023-05-01T00:00:00Z", RegistrationDateTime: "2023-06-02T00:00:00Z"}, false},
+		{"ordered before date, not registered yet", Domain{OrderDateTime: "2023-05-31T23:59:59Z"}, true},
+		{"ordered after date, not registered yet", Domain{OrderDateTime: "2023-06-01T00:00:01Z"}, false},
+		{"unreadable registration date falls back to order date", Domain{OrderDateTime: "2023-06-02T00:00:00Z", RegistrationDateTime: "02.06.2023"}, false},
+		{"unreadable start dates", Domain{OrderDateTime: "01.06.2023", RegistrationDateTime: "02.06.2023"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.domain.IsInPortfolioAt(date))
+		})
 	}
 }
 
-func BenchmarkDomain_IsBelowCutoff(b *testing.B) {
-	cutoffDate := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
-	domain := Domain{
-		Name:           "benchmark.com",
-		DeleteDateTime: "2023-07-15T10:30:00Z",
+func TestDomain_IsActiveBetween(t *testing.T) {
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		domain   Domain
+		expected bool
+	}{
+		{"no dates", Domain{}, true},
+		{"active through the whole range", Domain{RegistrationDateTime: "2020-01-01T00:00:00Z"}, true},
+		{"registered in the range", Domain{RegistrationDateTime: "2023-03-01T00:00:00Z"}, true},
+		{"registered at the end", Domain{RegistrationDateTime: "2023-06-01T00:00:00Z"}, true},
+		{"registered just after the end", Domain{RegistrationDateTime: "2023-06-01T00:00:01Z"}, false},
+		{"ordered after the end, not registered yet", Domain{OrderDateTime: "2023-07-01T00:00:00Z"}, false},
+		{"deleted in the range", Domain{RegistrationDateTime: "2020-01-01T00:00:00Z", DeleteDateTime: "2023-03-01T00:00:00Z"}, true},
+		{"deleted after the range", Domain{DeleteDateTime: "2023-07-15T10:30:00Z"}, true},
+		{"deleted at the start", Domain{DeleteDateTime: "2023-01-01T00:00:00Z"}, false},
+		{"deleted just after the start", Domain{DeleteDateTime: "2023-01-01T00:00:01Z"}, true},
+		{"deleted just before the start", Domain{DeleteDateTime: "2022-12-31T23:59:59Z"}, false},
+		{"unreadable delete date", Domain{DeleteDateTime: "31.12.2022"}, true},
+		{"registered and deleted in the range", Domain{RegistrationDateTime: "2023-02-01T00:00:00Z", DeleteDateTime: "2023-03-01T00:00:00Z"}, true},
+		{"deleted when registered", Domain{RegistrationDateTime: "2023-02-01T00:00:00Z", DeleteDateTime: "2023-02-01T00:00:00Z"}, false},
 	}
 
-	for i := 0; i < b.N; i++ {
-		_ = domain.IsBelowCutoff(cutoffDate)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.domain.IsActiveBetween(from, to))
+		})
 	}
+
+	t.Run("a range of one instant is the portfolio at that instant", func(t *testing.T) {
+		for _, tt := range tests {
+			assert.Equal(t, tt.domain.IsInPortfolioAt(to), tt.domain.IsActiveBetween(to, to), tt.name)
+		}
+	})
 }
 
-func TestFetchNicmanagerAPI(t *testing.T) {
+func TestDomain_ParseDates(t *testing.T) {
+	domain := Domain{
+		Name:                 "example.com",
+		OrderDateTime:        "2023-01-01T10:00:00Z",
+		RegistrationDateTime: "2023-01-02",
+		DeleteDateTime:       "",
+	}
+
+	dates, errs := domain.parseDates()
+
+	assert.Equal(t, time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC), dates.Order)
+	assert.True(t, dates.Registration.IsZero(), "Unreadable dates should stay zero")
+	assert.True(t, dates.Delete.IsZero(), "Empty dates should stay zero")
+
+	require.Len(t, errs, 1, "Only the unreadable date is an error, an empty one is not")
+	assert.Equal(t, "example.com", errs[0].Domain)
+	assert.Equal(t, "registration_datetime", errs[0].Field)
+	assert.Equal(t, "2023-01-02", errs[0].Value)
+	assert.EqualError(t, errs[0], `domain example.com: invalid registration_datetime "2023-01-02": not an RFC 3339 timestamp: parsing time "2023-01-02" as "2006-01-02T15:04:05Z07:00": cannot parse "" as "T"`)
+}
+
+func TestParseCutoffDate(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
 	tests := []struct {
-		name           string
-		responseBody   string
-		responseStatus int
-		expectedError  bool
-		login          string
-		password       string
-		pageNo         int
+		name        string
+		value       string
+		loc         *time.Location
+		expected    time.Time
+		expectError bool
 	}{
 		{
-			name:           "successful API call",
-			responseBody:   `[{"name":"example.com","order_status":"active","order_datetime":"2023-01-01T00:00:00Z","registration_datetime":"2023-01-01T00:00:00Z","delete_datetime":""}]`,
-			responseStatus: 200,
-			expectedError:  false,
-			login:          "testuser",
-			password:       "testpass",
-			pageNo:         1,
+			name:     "end of day in UTC",
+			value:    "2023-06-01",
+			loc:      time.UTC,
+			expected: time.Date(2023, 6, 1, 23, 59, 59, 999999999, time.UTC),
+		},
+		{
+			name:     "end of day in Berlin summer time",
+			value:    "2023-06-01",
+			loc:      berlin,
+			expected: time.Date(2023, 6, 1, 21, 59, 59, 999999999, time.UTC),
+		},
+		{
+			name:     "23 hour day at the start of summer time",
+			value:    "2023-03-26",
+			loc:      berlin,
+			expected: time.Date(2023, 3, 26, 21, 59, 59, 999999999, time.UTC),
+		},
+		{
+			name:     "25 hour day at the end of summer time",
+			value:    "2023-10-29",
+			loc:      berlin,
+			expected: time.Date(2023, 10, 29, 22, 59, 59, 999999999, time.UTC),
+		},
+		{
+			name:     "new year's eve",
+			value:    "2023-12-31",
+			loc:      berlin,
+			expected: time.Date(2023, 12, 31, 22, 59, 59, 999999999, time.UTC),
 		},
 		{
-			name:           "empty response",
-			responseBody:   `[]`,
-			responseStatus: 200,
-			expectedError:  false,
-			login:          "testuser",
-			password:       "testpass",
-			pageNo:         1,
+			name:     "leap day",
+			value:    "2024-02-29",
+			loc:      time.UTC,
+			expected: time.Date(2024, 2, 29, 23, 59, 59, 999999999, time.UTC),
 		},
 		{
-			name:           "unauthorized error",
-			responseBody:   `{"error":"unauthorized"}`,
-			responseStatus: 401,
-			expectedError:  true,
-			login:          "wronguser",
-			password:       "wrongpass",
-			pageNo:         1,
+			name:        "invalid day",
+			value:       "2023-02-29",
+			loc:         time.UTC,
+			expectError: true,
 		},
 		{
-			name:           "server error",
-			responseBody:   `{"error":"internal server error"}`,
-			responseStatus: 500,
-			expectedError:  true,
-			login:          "testuser",
-			password:       "testpass",
-			pageNo:         1,
+			name:        "wrong format",
+			value:       "01.06.2023",
+			loc:         time.UTC,
+			expectError: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create a test server
-			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				// Verify the request
-				assert.Equal(t, "GET", r.Method)
-				assert.Contains(t, r.URL.Path, "/v1/domains")
-				assert.Equal(t, "application/json", r.Header.Get("Accept"))
-
-				// Check basic auth
-				username, password, ok := r.BasicAuth()
-				assert.True(t, ok, "Basic auth should be present")
-				assert.Equal(t, tt.login, username)
-				assert.Equal(t, tt.password, password)
-
-				// Check query parameters
-				assert.Equal(t, "100", r.URL.Query().Get("limit"))
-				assert.Equal(t, "1", r.URL.Query().Get("page"))
-
-				w.WriteHeader(tt.responseStatus)
-				w.Write([]byte(tt.responseBody))
-			}))
-			defer server.Close()
-
-			// Create a custom client that uses our test server
-			client := http.Client{}
-
-			// We need to modify the fetchNicmanagerAPI function to accept a custom URL for testing
-			// For now, let's test the logic by creating a custom version
-			result, err := fetchNicmanagerAPIWithURL(client, tt.login, tt.password, tt.pageNo, server.URL+"/v1/domains")
-
-			if tt.expectedError {
-				assert.Error(t, err, "Expected an error for status %d", tt.responseStatus)
-			} else {
-				require.NoError(t, err, "Unexpected error for successful request")
-				assert.Equal(t, tt.responseBody, string(result), "Response body should match")
+			result, err := parseCutoffDate(tt.value, tt.loc)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
 			}
+			require.NoError(t, err)
+			assert.True(t, tt.expected.Equal(result), "expected %s, got %s", tt.expected, result.UTC())
+		})
+	}
+}
+
+func TestDomain_IsBelowCutoff_TimeZones(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		cutoff         string
+		loc            *time.Location
+		deleteDateTime string
+		expected       bool
+	}{
+		{"UTC: deleted at the start of the cutoff day", "2023-06-01", time.UTC, "2023-06-01T00:00:00Z", false},
+		{"UTC: deleted in the last second of the cutoff day", "2023-06-01", time.UTC, "2023-06-01T23:59:59.999Z", false},
+		{"UTC: deleted at midnight after the cutoff day", "2023-06-01", time.UTC, "2023-06-02T00:00:00Z", true},
+		{"Berlin: deleted late on the cutoff day, already the next day in UTC", "2023-06-01", berlin, "2023-06-01T21:30:00Z", false},
+		{"Berlin: deleted at local midnight after the cutoff day", "2023-06-01", berlin, "2023-06-01T22:00:00Z", true},
+		{"Berlin spring forward: last second of the 23 hour day", "2023-03-26", berlin, "2023-03-26T21:59:59Z", false},
+		{"Berlin spring forward: first second of the next day", "2023-03-26", berlin, "2023-03-26T22:00:00Z", true},
+		{"Berlin fall back: repeated hour still belongs to the day", "2023-10-29", berlin, "2023-10-29T01:30:00Z", false},
+		{"Berlin fall back: last second of the 25 hour day", "2023-10-29", berlin, "2023-10-29T22:59:59Z", false},
+		{"Berlin fall back: first second of the next day", "2023-10-29", berlin, "2023-10-29T23:00:00Z", true},
+		{"Berlin year boundary: last second of the year", "2023-12-31", berlin, "2023-12-31T22:59:59Z", false},
+		{"Berlin year boundary: new year in local time", "2023-12-31", berlin, "2023-12-31T23:00:00Z", true},
+		{"Berlin year boundary: offset notation", "2023-12-31", berlin, "2024-01-01T00:00:00+01:00", true},
+		{"New York year boundary: already the new year in UTC", "2023-12-31", newYork, "2024-01-01T04:59:59Z", false},
+		{"New York year boundary: new year in local time", "2023-12-31", newYork, "2024-01-01T05:00:00Z", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cutoffDate, err := parseCutoffDate(tt.cutoff, tt.loc)
+			require.NoError(t, err)
+
+			domain := Domain{Name: "example.com", DeleteDateTime: tt.deleteDateTime}
+			assert.Equal(t, tt.expected, domain.IsBelowCutoff(cutoffDate))
+		})
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		date     time.Time
+		loc      *time.Location
+		expected string
+	}{
+		{"zero time is empty", time.Time{}, berlin, ""},
+		{"nil location is UTC", time.Date(2023, 12, 31, 23, 30, 0, 0, time.UTC), nil, "2023-12-31"},
+		{"UTC", time.Date(2023, 12, 31, 23, 30, 0, 0, time.UTC), time.UTC, "2023-12-31"},
+		{"new year in Berlin", time.Date(2023, 12, 31, 23, 30, 0, 0, time.UTC), berlin, "2024-01-01"},
+		{"summer time in Berlin", time.Date(2023, 6, 30, 22, 0, 0, 0, time.UTC), berlin, "2023-07-01"},
+		{"winter time in Berlin", time.Date(2023, 1, 31, 22, 59, 59, 0, time.UTC), berlin, "2023-01-31"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, formatDate(tt.date, tt.loc))
 		})
 	}
 }
 
-// Helper function for testing with custom URL
-func fetchNicmanagerAPIWithURL(client http.Client, login string, password string, pageNo int, baseURL string) ([]byte, error) {
-	req, rErr := http.NewRequest("GET", baseURL+"?limit=100&page="+fmt.Sprintf("%d", pageNo), nil)
-	req.Header.Add("Accept", "application/json")
-	req.SetBasicAuth(login, password)
-	if rErr != nil {
-		return nil, rErr
+func TestDomain_TLDAndSLD(t *testing.T) {
+	tests := []struct {
+		name        string
+		expectedTLD string
+		expectedSLD string
+	}{
+		{"example.com", "com", "example"},
+		{"Example.DE", "de", "example"},
+		{"shop.example.co.uk", "uk", "co"},
+		{"müller.de", "de", "müller"},
+		{"localhost", "", ""},
+		{"", "", ""},
 	}
 
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	for _, tt := range tests {
+		domain := Domain{Name: tt.name}
+		assert.Equal(t, tt.expectedTLD, domain.TLD(), "TLD of %q", tt.name)
+		assert.Equal(t, tt.expectedSLD, domain.SLD(), "SLD of %q", tt.name)
 	}
-	defer res.Body.Close()
+}
 
-	if res.StatusCode != 200 {
-		return nil, errors.New(fmt.Sprintf("status code error: %d %s", res.StatusCode, res.Status))
+// Benchmark tests to ensure performance is acceptable
+func BenchmarkParseAPIdate(b *testing.B) {
+	dateString := "2023-03-15T14:30:45Z"
+	for i := 0; i < b.N; i++ {
+		_, _ = parseAPIdate(dateString)
 	}
+}
 
-	return io.ReadAll(res.Body)
+func BenchmarkDomain_IsBelowCutoff(b *testing.B) {
+	cutoffDate := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	domain := Domain{
+		Name:           "benchmark.com",
+		DeleteDateTime: "2023-07-15T10:30:00Z",
+	}
+
+	for i := 0; i < b.N; i++ {
+		_ = domain.IsBelowCutoff(cutoffDate)
+	}
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mariow/nicmanager-export/nicmanager"
+)
+
+// userMessage turns an export error into a message for the GUI
+func userMessage(err error) string {
+	var authErr *nicmanager.AuthError
+	var rateErr *nicmanager.RateLimitError
+	var statusErr *nicmanager.APIStatusError
+	var decodeErr *nicmanager.DecodeError
+	var dateErr *DateError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "Der Export wurde abgebrochen."
+	case errors.As(err, &authErr):
+		return "Die Anmeldung bei der Nicmanager API ist fehlgeschlagen. Bitte Benutzer und Passwort prüfen."
+	case errors.As(err, &rateErr):
+		if rateErr.RetryAfter > 0 {
+			return fmt.Sprintf("Die Nicmanager API meldet zu viele Anfragen. Bitte in %s erneut versuchen.", rateErr.RetryAfter)
+		}
+		return "Die Nicmanager API meldet zu viele Anfragen. Bitte später erneut versuchen."
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("Die Nicmanager API antwortete mit %s.", statusErr.Status)
+	case errors.As(err, &decodeErr):
+		return fmt.Sprintf("Die Antwort der Nicmanager API (Seite %d) konnte nicht gelesen werden.", decodeErr.Page)
+	case errors.As(err, &dateErr):
+		return fmt.Sprintf("Das Feld %s der Domain %s enthält das unlesbare Datum %q.", dateErr.Field, dateErr.Domain, dateErr.Value)
+	default:
+		return err.Error()
+	}
+}
+
+// maxReportedIssues limits the length of the issue report shown in the GUI
+const maxReportedIssues = 20
+
+// issueReport lists unreadable dates for the GUI, one per line
+func issueReport(issues []*DateError) string {
+	var report strings.Builder
+	report.WriteString("Diese Datumsangaben konnten nicht gelesen werden und wurden leer exportiert:\n")
+	for i, issue := range issues {
+		if i == maxReportedIssues {
+			fmt.Fprintf(&report, "... und %d weitere", len(issues)-maxReportedIssues)
+			break
+		}
+		fmt.Fprintf(&report, "%s: %s %q\n", issue.Domain, issue.Field, issue.Value)
+	}
+	return strings.TrimRight(report.String(), "\n")
+}
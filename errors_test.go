@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mariow/nicmanager-export/nicmanager"
+)
+
+func TestUserMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "canceled",
+			err:      fmt.Errorf("nicmanager: %w", context.Canceled),
+			expected: "Der Export wurde abgebrochen.",
+		},
+		{
+			name:     "wrong credentials",
+			err:      &nicmanager.AuthError{APIStatusError: nicmanager.APIStatusError{StatusCode: 401, Status: "401 Unauthorized"}},
+			expected: "Die Anmeldung bei der Nicmanager API ist fehlgeschlagen. Bitte Benutzer und Passwort prüfen.",
+		},
+		{
+			name:     "rate limited with retry after",
+			err:      &nicmanager.RateLimitError{RetryAfter: 2 * time.Minute},
+			expected: "Die Nicmanager API meldet zu viele Anfragen. Bitte in 2m0s erneut versuchen.",
+		},
+		{
+			name:     "rate limited",
+			err:      &nicmanager.RateLimitError{},
+			expected: "Die Nicmanager API meldet zu viele Anfragen. Bitte später erneut versuchen.",
+		},
+		{
+			name:     "unexpected status",
+			err:      &nicmanager.APIStatusError{StatusCode: 500, Status: "500 Internal Server Error"},
+			expected: "Die Nicmanager API antwortete mit 500 Internal Server Error.",
+		},
+		{
+			name:     "broken JSON",
+			err:      &nicmanager.DecodeError{Path: "/domains", Page: 7, Err: errors.New("unexpected EOF")},
+			expected: "Die Antwort der Nicmanager API (Seite 7) konnte nicht gelesen werden.",
+		},
+		{
+			name:     "unreadable date in strict mode",
+			err:      fmt.Errorf("strict mode: %w", &DateError{Domain: "example.com", Field: "order_datetime", Value: "01.01.2022"}),
+			expected: `Das Feld order_datetime der Domain example.com enthält das unlesbare Datum "01.01.2022".`,
+		},
+		{
+			name:     "other errors are shown as they are",
+			err:      errors.New("open Export.csv: permission denied"),
+			expected: "open Export.csv: permission denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, userMessage(tt.err))
+		})
+	}
+}
+
+func TestIssueReport(t *testing.T) {
+	issues := []*DateError{
+		{Domain: "a.com", Field: "order_datetime", Value: "01.01.2022"},
+		{Domain: "b.com", Field: "delete_datetime", Value: "soon"},
+	}
+	assert.Equal(t, "Diese Datumsangaben konnten nicht gelesen werden und wurden leer exportiert:\n"+
+		"a.com: order_datetime \"01.01.2022\"\n"+
+		"b.com: delete_datetime \"soon\"", issueReport(issues))
+
+	for i := 0; i < 30; i++ {
+		issues = append(issues, &DateError{Domain: fmt.Sprintf("%d.com", i), Field: "order_datetime", Value: "x"})
+	}
+	report := issueReport(issues)
+	assert.True(t, strings.HasSuffix(report, "... und 12 weitere"), "Long reports should be cut off: %s", report)
+}
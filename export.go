@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mariow/nicmanager-export/nicmanager"
+)
+
+// default client side rate limit, well below what the API tolerates, and
+// the number of pages fetched in parallel
+const (
+	defaultRequestRate  = 5.0
+	defaultRequestBurst = 5
+	defaultConcurrency  = 4
+)
+
+// newAPIClient creates an API client with the defaults of this tool, debug
+// output goes to the standard logger
+func newAPIClient(login string, password string, opts ...nicmanager.Option) *nicmanager.Client {
+	defaults := []nicmanager.Option{
+		nicmanager.WithLogger(log.Default()),
+		nicmanager.WithRateLimit(defaultRequestRate, defaultRequestBurst),
+		nicmanager.WithConcurrency(defaultConcurrency),
+	}
+	return nicmanager.NewClient(login, password, append(defaults, opts...)...)
+}
+
+// exportOptions control which domains exportDomains exports and how
+type exportOptions struct {
+	// CutoffDate is the last instant of the cutoff day, see parseCutoffDate
+	CutoffDate time.Time
+	// Mode selects how the inventory at CutoffDate is determined, empty
+	// means modeCutoff
+	Mode inventoryMode
+	// StartDate is the first instant of the range of modeBetween, which ends
+	// at CutoffDate
+	StartDate time.Time
+	// Location is the time zone the dates are written in, nil means UTC
+	Location *time.Location
+	// Format selects the ExportWriter, empty means CSV
+	Format outputFormat
+	// Columns are the columns of the tabular formats, nil means
+	// defaultColumns. JSON, NDJSON and SQLite always contain all fields.
+	Columns []column
+	// CSV is the dialect of the CSV output, the zero value means
+	// defaultCSVDialect
+	CSV csvDialect
+	// Filter selects the exported domains in addition to the cutoff date,
+	// nil exports all of them
+	Filter *domainFilter
+	// Snapshot stores the fetched domain list in the snapshot store, nil
+	// stores nothing
+	Snapshot *snapshotRecorder
+	// Strict fails the export on the first record with an unreadable date
+	// instead of exporting it with empty date cells
+	Strict bool
+	// KeepPartial keeps the output of a failed export as .partial file for
+	// debugging, see writeFileAtomic
+	KeepPartial bool
+}
+
+// tableColumns returns the columns the tabular writers write
+func (o *exportOptions) tableColumns() []column {
+	if len(o.Columns) == 0 {
+		return defaultColumns
+	}
+	return o.Columns
+}
+
+// csvDialect returns the dialect the CSV writer uses
+func (o *exportOptions) csvDialect() csvDialect {
+	if o.CSV.Delimiter == 0 {
+		return defaultCSVDialect
+	}
+	return o.CSV
+}
+
+// exportResult summarizes a finished export
+type exportResult struct {
+	RecordsWritten int
+	// Issues lists the dates that could not be read, the affected records are
+	// exported with empty date cells
+	Issues []*DateError
+	// Snapshot is the stored snapshot of the fetched domain list, if one was
+	// requested; SnapshotErr tells why it could not be stored, which does
+	// not fail the export
+	Snapshot    snapshotInfo
+	SnapshotErr error
+	// PartialPath is the incomplete output kept by a failed export with
+	// KeepPartial set
+	PartialPath string
+}
+
+// checkOutputPath reports before the fetch whether an export can be written
+// to path: its directory must exist and be writable, and an existing path
+// must be a writable file. Nothing is created or truncated.
+func checkOutputPath(path string) error {
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return fmt.Errorf("%s is a directory", path)
+	case err == nil:
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		return file.Close()
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	probe, err := os.CreateTemp(dir, ".nicmanager-export-*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// exportDomainsToFile runs exportDomains into a temporary file that replaces
+// path once the export is complete, so a failed or canceled export leaves no
+// incomplete file and an existing file unchanged. Existing SQLite databases
+// are updated in place instead, a failed export rolls back.
+func exportDomainsToFile(domains iter.Seq2[nicmanager.Domain, error], opts exportOptions, path string) (exportResult, error) {
+	if opts.KeepPartial && !opts.Format.streams() {
+		opts.Snapshot.discard()
+		return exportResult{}, fmt.Errorf("the partial output of %s cannot be kept, only that of csv, json and ndjson", opts.Format)
+	}
+	if opts.Format == formatSQLite {
+		return exportDomainsToSQLite(domains, opts, path)
+	}
+
+	var result exportResult
+	partial, err := writeFileAtomic(path, opts.KeepPartial, func(out io.Writer) (err error) {
+		result, err = exportDomains(domains, opts, out)
+		return err
+	})
+	// the snapshot is incomplete unless the list was read to the end
+	if err != nil && result.Snapshot.ID == "" {
+		opts.Snapshot.discard()
+	}
+	result.PartialPath = partial
+	return result, err
+}
+
+// exportDomainsToSQLite updates the database at path in a transaction. A new
+// database is created under a temporary name and renamed once complete,
+// like the files of the other formats.
+func exportDomainsToSQLite(domains iter.Seq2[nicmanager.Domain, error], opts exportOptions, path string) (exportResult, error) {
+	target := path
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		file, err := createTempBeside(path)
+		if err != nil {
+			opts.Snapshot.discard()
+			return exportResult{}, err
+		}
+		file.Close()
+		target = file.Name()
+		defer os.Remove(target)
+	}
+
+	writer, err := openSQLiteExportWriter(target, opts)
+	if err != nil {
+		opts.Snapshot.discard()
+		return exportResult{}, err
+	}
+	result, err := writeDomains(domains, opts, writer)
+	if err != nil {
+		return result, err
+	}
+	if target != path {
+		err = os.Rename(target, path)
+	}
+	return result, err
+}
+
+// exportDomains writes all domains of the list, e.g. fetched from the API or
+// read from a stored snapshot, in the inventory selected by the mode that
+// pass the filter in the selected format to out until the list ends or
+// fails
+func exportDomains(domains iter.Seq2[nicmanager.Domain, error], opts exportOptions, out io.Writer) (exportResult, error) {
+	writer, err := newExportWriter(opts.Format, out, opts)
+	if err != nil {
+		opts.Snapshot.discard()
+		return exportResult{}, err
+	}
+	return writeDomains(domains, opts, writer)
+}
+
+// writeDomains runs the export loop of exportDomains with any ExportWriter,
+// writer is closed once the whole list is read and aborted if the export
+// fails before, with KeepPartial a streaming writer flushes what it has after
+// a failure. Every domain of the list is added to opts.Snapshot, which is
+// committed once the whole list is read.
+func writeDomains(domains iter.Seq2[nicmanager.Domain, error], opts exportOptions, writer ExportWriter) (result exportResult, err error) {
+	if opts.Snapshot != nil {
+		defer func() {
+			if err != nil {
+				opts.Snapshot.discard()
+			}
+		}()
+	}
+	// a failing Close cleans up itself
+	closing := false
+	if aborter, ok := writer.(aborter); ok {
+		defer func() {
+			if err != nil && !closing {
+				aborter.abort()
+			}
+		}()
+	}
+	if flusher, ok := writer.(partialFlusher); ok && opts.KeepPartial {
+		defer func() {
+			if err != nil {
+				flusher.flushPartial()
+			}
+		}()
+	}
+
+	if err := writer.WriteHeader(); err != nil {
+		return result, err
+	}
+
+	for apiDomain, err := range domains {
+		if err != nil {
+			return result, err
+		}
+
+		rowData := Domain(apiDomain)
+		if opts.Snapshot != nil {
+			opts.Snapshot.add(&rowData)
+		}
+
+		// parse dates, unreadable ones are reported instead of turning into 0001-01-01
+		dates, dateErrs := rowData.parseDates()
+		if len(dateErrs) > 0 && opts.Strict {
+			return result, fmt.Errorf("strict mode: %w", dateErrs[0])
+		}
+		for _, dateErr := range dateErrs {
+			log.Println(dateErr)
+		}
+		result.Issues = append(result.Issues, dateErrs...)
+
+		record := &exportRecord{Domain: rowData, Dates: dates}
+		if opts.Mode.inInventory(&rowData, opts.StartDate, opts.CutoffDate) && opts.Filter.match(record) {
+			if err := writer.WriteDomain(record); err != nil {
+				return result, err
+			}
+			result.RecordsWritten++
+		}
+	}
+
+	// the list is complete, so the snapshot is, whatever happens to the output
+	if opts.Snapshot != nil {
+		result.Snapshot, result.SnapshotErr = opts.Snapshot.commit()
+		opts.Snapshot = nil
+	}
+
+	closing = true
+	return result, writer.Close()
+}
+
+// formatDate formats a date as the calendar day in loc, the zero time
+// becomes an empty cell
+func formatDate(date time.Time, loc *time.Location) string {
+	if date.IsZero() {
+		return ""
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	return date.In(loc).Format("2006-01-02")
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// filterSettings are the user facing domain filters of the config file, the
+// command line and the GUI. A domain is exported if it matches every filter
+// that is set; within a list one matching entry is enough.
+type filterSettings struct {
+	// TLDs are top level domains like "com" or ".net"
+	TLDs []string `toml:"tld"`
+	// Names are glob patterns like "*shop*.de", matched against the whole
+	// domain name
+	Names []string `toml:"name"`
+	// NameRegex is a regular expression matched against the domain name
+	NameRegex string `toml:"name_regex"`
+	// Statuses are order statuses like "active"
+	Statuses []string `toml:"status"`
+	// Ordered, Registered and Deleted are date ranges like
+	// 2019-01-01..2020-12-31, see parseDateRange
+	Ordered    string `toml:"ordered"`
+	Registered string `toml:"registered"`
+	Deleted    string `toml:"deleted"`
+}
+
+// override returns s with every field that is set in other replaced
+func (s filterSettings) override(other filterSettings) filterSettings {
+	if len(other.TLDs) > 0 {
+		s.TLDs = other.TLDs
+	}
+	if len(other.Names) > 0 {
+		s.Names = other.Names
+	}
+	if other.NameRegex != "" {
+		s.NameRegex = other.NameRegex
+	}
+	if len(other.Statuses) > 0 {
+		s.Statuses = other.Statuses
+	}
+	if other.Ordered != "" {
+		s.Ordered = other.Ordered
+	}
+	if other.Registered != "" {
+		s.Registered = other.Registered
+	}
+	if other.Deleted != "" {
+		s.Deleted = other.Deleted
+	}
+	return s
+}
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// dateRange is a range of instants, both ends inclusive, a zero end is open
+type dateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// parseDateRange parses FROM..TO with dates in the form YYYY-MM-DD, either
+// side may be left empty. A single date is the range of that day. The days
+// are whole days in loc, like the cutoff date.
+func parseDateRange(value string, loc *time.Location) (dateRange, error) {
+	var r dateRange
+	formatErr := fmt.Errorf("invalid date range %q, expected YYYY-MM-DD..YYYY-MM-DD, either side may be empty", value)
+
+	from, to, isRange := strings.Cut(strings.TrimSpace(value), "..")
+	if !isRange {
+		to = from
+	}
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from == "" && to == "" {
+		return r, formatErr
+	}
+
+	if from != "" {
+		day, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return r, formatErr
+		}
+		r.From = day
+	}
+	if to != "" {
+		end, err := parseCutoffDate(to, loc)
+		if err != nil {
+			return r, formatErr
+		}
+		r.To = end
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && r.From.After(r.To) {
+		return r, fmt.Errorf("invalid date range %q, the start is after the end", value)
+	}
+	return r, nil
+}
+
+// contains reports whether date lies in the range. A missing date is in no
+// range.
+func (r *dateRange) contains(date time.Time) bool {
+	if date.IsZero() {
+		return false
+	}
+	return (r.From.IsZero() || !date.Before(r.From)) && (r.To.IsZero() || !date.After(r.To))
+}
+
+// domainFilter is the compiled form of filterSettings
+type domainFilter struct {
+	tlds       map[string]bool
+	names      []string
+	nameRegex  *regexp.Regexp
+	statuses   map[string]bool
+	ordered    *dateRange
+	registered *dateRange
+	deleted    *dateRange
+}
+
+// compile checks the settings and turns them into a domainFilter, with the
+// date ranges in loc. It returns nil if no filter is set.
+func (s filterSettings) compile(loc *time.Location) (*domainFilter, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	f := &domainFilter{}
+	active := false
+
+	for _, tld := range s.TLDs {
+		if f.tlds == nil {
+			f.tlds = make(map[string]bool)
+		}
+		f.tlds[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tld), "."))] = true
+		active = true
+	}
+
+	for _, pattern := range s.Names {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+		}
+		f.names = append(f.names, pattern)
+		active = true
+	}
+
+	if s.NameRegex != "" {
+		re, err := regexp.Compile(s.NameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name regex: %w", err)
+		}
+		f.nameRegex = re
+		active = true
+	}
+
+	for _, status := range s.Statuses {
+		if f.statuses == nil {
+			f.statuses = make(map[string]bool)
+		}
+		f.statuses[strings.ToLower(strings.TrimSpace(status))] = true
+		active = true
+	}
+
+	for _, dr := range []struct {
+		value  string
+		target **dateRange
+	}{
+		{s.Ordered, &f.ordered},
+		{s.Registered, &f.registered},
+		{s.Deleted, &f.deleted},
+	} {
+		if dr.value == "" {
+			continue
+		}
+		r, err := parseDateRange(dr.value, loc)
+		if err != nil {
+			return nil, err
+		}
+		*dr.target = &r
+		active = true
+	}
+
+	if !active {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// match reports whether record passes the filter, a nil filter passes every
+// record. Names are compared case insensitively; a record whose date could
+// not be read does not match a range on that date.
+func (f *domainFilter) match(record *exportRecord) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.tlds != nil && !f.tlds[record.Domain.TLD()] {
+		return false
+	}
+
+	name := strings.ToLower(record.Domain.Name)
+	if f.names != nil {
+		matched := false
+		for _, pattern := range f.names {
+			if ok, _ := path.Match(pattern, name); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if f.nameRegex != nil && !f.nameRegex.MatchString(record.Domain.Name) {
+		return false
+	}
+
+	if f.statuses != nil && !f.statuses[strings.ToLower(record.Domain.OrderStatus)] {
+		return false
+	}
+
+	if f.ordered != nil && !f.ordered.contains(record.Dates.Order) {
+		return false
+	}
+	if f.registered != nil && !f.registered.contains(record.Dates.Registration) {
+		return false
+	}
+	if f.deleted != nil && !f.deleted.contains(record.Dates.Delete) {
+		return false
+	}
+	return true
+}
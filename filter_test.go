@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDateRange(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	endOfDay := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day+1, 0, 0, 0, 0, berlin).Add(-time.Nanosecond)
+	}
+
+	tests := []struct {
+		value       string
+		expected    dateRange
+		expectError bool
+	}{
+		{"2019-01-01..2020-12-31", dateRange{From: time.Date(2019, 1, 1, 0, 0, 0, 0, berlin), To: endOfDay(2020, 12, 31)}, false},
+		{"2019-01-01..", dateRange{From: time.Date(2019, 1, 1, 0, 0, 0, 0, berlin)}, false},
+		{"..2020-12-31", dateRange{To: endOfDay(2020, 12, 31)}, false},
+		{" 2019-01-01 .. 2020-12-31 ", dateRange{From: time.Date(2019, 1, 1, 0, 0, 0, 0, berlin), To: endOfDay(2020, 12, 31)}, false},
+		{"2024-03-31", dateRange{From: time.Date(2024, 3, 31, 0, 0, 0, 0, berlin), To: endOfDay(2024, 3, 31)}, false},
+		{"..", dateRange{}, true},
+		{"2019", dateRange{}, true},
+		{"2019-01-01..2020-13-01", dateRange{}, true},
+		{"2020-12-31..2019-01-01", dateRange{}, true},
+	}
+
+	for _, tt := range tests {
+		r, err := parseDateRange(tt.value, berlin)
+		if tt.expectError {
+			assert.Error(t, err, "range %q", tt.value)
+			continue
+		}
+		require.NoError(t, err, "range %q", tt.value)
+		assert.True(t, tt.expected.From.Equal(r.From), "range %q: from %v", tt.value, r.From)
+		assert.True(t, tt.expected.To.Equal(r.To), "range %q: to %v", tt.value, r.To)
+	}
+}
+
+func TestDateRange_Contains(t *testing.T) {
+	r, err := parseDateRange("2023-01-01..2023-12-31", time.UTC)
+	require.NoError(t, err)
+
+	assert.True(t, r.contains(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)), "The first instant belongs to the range")
+	assert.True(t, r.contains(time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC)), "The whole last day belongs to the range")
+	assert.False(t, r.contains(time.Date(2022, 12, 31, 23, 59, 59, 0, time.UTC)))
+	assert.False(t, r.contains(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, r.contains(time.Time{}), "A missing date is in no range")
+}
+
+func TestFilterSettings_Compile(t *testing.T) {
+	filter, err := filterSettings{}.compile(time.UTC)
+	require.NoError(t, err)
+	assert.Nil(t, filter, "Without settings there is no filter")
+	assert.True(t, filter.match(testRecords(t)[0]), "A nil filter passes every record")
+
+	for _, settings := range []filterSettings{
+		{Names: []string{"[a-"}},
+		{NameRegex: "(example"},
+		{Registered: "2019-01-01...2020"},
+	} {
+		_, err := settings.compile(time.UTC)
+		assert.Error(t, err, "settings %+v", settings)
+	}
+}
+
+func TestDomainFilter_Match(t *testing.T) {
+	// example.com: active, ordered 2023-01-01, registered 2023-01-02
+	// müller.de: deleted, ordered 2022-01-01, registered 2022-01-02, deleted 2023-12-31 23:30 UTC
+	records := testRecords(t)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		settings filterSettings
+		loc      *time.Location
+		expected []string
+	}{
+		{"TLD", filterSettings{TLDs: []string{".COM", "net"}}, nil, []string{"example.com"}},
+		{"name glob", filterSettings{Names: []string{"*ler.*"}}, nil, []string{"müller.de"}},
+		{"name globs are alternatives", filterSettings{Names: []string{"example.*", "*.de"}}, nil, []string{"example.com", "müller.de"}},
+		{"name glob is case insensitive", filterSettings{Names: []string{"EXAMPLE.COM"}}, nil, []string{"example.com"}},
+		{"name regex", filterSettings{NameRegex: `^ex`}, nil, []string{"example.com"}},
+		{"status", filterSettings{Statuses: []string{"Deleted"}}, nil, []string{"müller.de"}},
+		{"ordered range", filterSettings{Ordered: "2022-06-01.."}, nil, []string{"example.com"}},
+		{"registered day", filterSettings{Registered: "2022-01-02"}, nil, []string{"müller.de"}},
+		{"no delete date matches no delete range", filterSettings{Deleted: "..2030-01-01"}, nil, []string{"müller.de"}},
+		{"delete range in UTC", filterSettings{Deleted: "2023-12-31"}, time.UTC, []string{"müller.de"}},
+		{"delete range in the time zone", filterSettings{Deleted: "2023-12-31"}, berlin, nil},
+		{"all filters have to match", filterSettings{TLDs: []string{"com"}, Statuses: []string{"deleted"}}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := tt.settings.compile(tt.loc)
+			require.NoError(t, err)
+			require.NotNil(t, filter)
+
+			var matched []string
+			for _, record := range records {
+				if filter.match(record) {
+					matched = append(matched, record.Domain.Name)
+				}
+			}
+			assert.Equal(t, tt.expected, matched)
+		})
+	}
+}
+
+func TestDomainFilter_UnreadableDate(t *testing.T) {
+	domain := Domain{Name: "example.com", RegistrationDateTime: "yesterday"}
+	dates, errs := domain.parseDates()
+	require.Len(t, errs, 1)
+
+	filter, err := filterSettings{Registered: "2000-01-01.."}.compile(time.UTC)
+	require.NoError(t, err)
+	assert.False(t, filter.match(&exportRecord{Domain: domain, Dates: dates}), "An unreadable date matches no range")
+}
+
+func TestFilterSettings_Override(t *testing.T) {
+	config := filterSettings{TLDs: []string{"com"}, Statuses: []string{"active"}, Registered: "2019-01-01.."}
+	flags := filterSettings{TLDs: []string{"de"}, Deleted: "..2024-01-01"}
+
+	assert.Equal(t, filterSettings{TLDs: []string{"de"}, Statuses: []string{"active"}, Registered: "2019-01-01..", Deleted: "..2024-01-01"}, config.override(flags))
+	assert.Equal(t, config, config.override(filterSettings{}))
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"com", "net"}, splitList(" com, ,net,"))
+	assert.Nil(t, splitList(""))
+}
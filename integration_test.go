@@ -1,15 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/mariow/nicmanager-export/nicmanager"
 )
 
 func TestDomainJSONUnmarshaling(t *testing.T) {
@@ -176,3 +185,316 @@ func TestDomainFilteringWithCutoff(t *testing.T) {
 	expected := []string{"active.com", "deleted-after.com"}
 	assert.Equal(t, expected, includedDomains, "Filtered domains don't match expected")
 }
+
+func TestExportDomains(t *testing.T) {
+	// two pages: a full one and a short one that ends the listing
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var domains []nicmanager.Domain
+		switch r.URL.Query().Get("page") {
+		case "1":
+			for i := 0; i < nicmanager.DefaultPageSize; i++ {
+				domains = append(domains, nicmanager.Domain{
+					Name:                 fmt.Sprintf("domain%03d.com", i),
+					OrderDateTime:        "2022-01-01T00:00:00Z",
+					RegistrationDateTime: "2022-01-02T00:00:00Z",
+				})
+			}
+		case "2":
+			domains = []nicmanager.Domain{
+				{
+					Name:                 "deleted-before.com",
+					OrderDateTime:        "2022-01-01T00:00:00Z",
+					RegistrationDateTime: "2022-01-02T00:00:00Z",
+					DeleteDateTime:       "2023-05-01T00:00:00Z",
+				},
+				{
+					Name:                 "deleted-after.com",
+					OrderDateTime:        "2022-01-01T00:00:00Z",
+					RegistrationDateTime: "2022-01-02T00:00:00Z",
+					DeleteDateTime:       "2023-07-01T00:00:00Z",
+				},
+			}
+		default:
+			t.Errorf("unexpected page %s", r.URL.Query().Get("page"))
+		}
+		json.NewEncoder(w).Encode(domains)
+	}))
+	defer server.Close()
+
+	client := nicmanager.NewClient("testuser", "testpass", nicmanager.WithBaseURL(server.URL))
+	cutoffDate := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	var out bytes.Buffer
+	result, err := exportDomains(client.Domains(context.Background(), nicmanager.DefaultPageSize), exportOptions{CutoffDate: cutoffDate}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, nicmanager.DefaultPageSize+1, result.RecordsWritten)
+	assert.Empty(t, result.Issues)
+
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, nicmanager.DefaultPageSize+2, "Expected header + all domains except deleted-before.com")
+	assert.Equal(t, []string{"Domain", "Order Date", "Reg Date", "Close Date"}, records[0])
+	assert.Equal(t, []string{"domain000.com", "2022-01-01", "2022-01-02", ""}, records[1])
+	assert.Equal(t, []string{"deleted-after.com", "2022-01-01", "2022-01-02", "2023-07-01"}, records[len(records)-1])
+}
+
+func TestExportDomains_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := nicmanager.NewClient("testuser", "wrongpass", nicmanager.WithBaseURL(server.URL))
+
+	var out bytes.Buffer
+	_, err := exportDomains(client.Domains(context.Background(), nicmanager.DefaultPageSize), exportOptions{CutoffDate: time.Now()}, &out)
+	assert.Error(t, err, "API errors should be returned instead of exiting")
+}
+
+// abortRecorder is an ExportWriter that records whether it was closed or
+// aborted
+type abortRecorder struct {
+	closeErr error
+	closed   bool
+	aborted  bool
+}
+
+func (w *abortRecorder) WriteHeader() error                     { return nil }
+func (w *abortRecorder) WriteDomain(record *exportRecord) error { return nil }
+func (w *abortRecorder) Close() error                           { w.closed = true; return w.closeErr }
+func (w *abortRecorder) abort()                                 { w.aborted = true }
+
+func TestWriteDomains_AbortsWriter(t *testing.T) {
+	failing := func(yield func(nicmanager.Domain, error) bool) {
+		if yield(nicmanager.Domain{Name: "example.com"}, nil) {
+			yield(nicmanager.Domain{}, errors.New("connection reset"))
+		}
+	}
+
+	writer := &abortRecorder{}
+	_, err := writeDomains(failing, exportOptions{}, writer)
+	require.Error(t, err)
+	assert.True(t, writer.aborted, "A failed export should abort the writer")
+	assert.False(t, writer.closed)
+
+	writer = &abortRecorder{}
+	_, err = writeDomains(func(yield func(nicmanager.Domain, error) bool) {
+		yield(nicmanager.Domain{Name: "example.com"}, nil)
+	}, exportOptions{}, writer)
+	require.NoError(t, err)
+	assert.True(t, writer.closed)
+	assert.False(t, writer.aborted, "A complete export should only close the writer")
+
+	writer = &abortRecorder{closeErr: errors.New("disk full")}
+	_, err = writeDomains(func(yield func(nicmanager.Domain, error) bool) {
+		yield(nicmanager.Domain{Name: "example.com"}, nil)
+	}, exportOptions{}, writer)
+	require.Error(t, err)
+	assert.False(t, writer.aborted, "A failing Close cleans up itself")
+}
+
+func TestExportDomainsToFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name":"example.com","order_datetime":"2023-01-01T00:00:00Z","registration_datetime":"2023-01-02T00:00:00Z"}]`))
+	}))
+	defer server.Close()
+
+	client := nicmanager.NewClient("testuser", "testpass", nicmanager.WithBaseURL(server.URL))
+	path := filepath.Join(t.TempDir(), "export.csv")
+
+	result, err := exportDomainsToFile(client.Domains(context.Background(), nicmanager.DefaultPageSize), exportOptions{CutoffDate: time.Now()}, path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.RecordsWritten)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Domain,Order Date,Reg Date,Close Date\nexample.com,2023-01-01,2023-01-02,\n", string(content))
+}
+
+func TestExportDomainsToFile_Canceled(t *testing.T) {
+	// the first page is full, the second one hangs until the export is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		var domains []nicmanager.Domain
+		for i := 0; i < nicmanager.DefaultPageSize; i++ {
+			domains = append(domains, nicmanager.Domain{Name: fmt.Sprintf("domain%03d.com", i)})
+		}
+		json.NewEncoder(w).Encode(domains)
+	}))
+	defer server.Close()
+
+	client := nicmanager.NewClient("testuser", "testpass", nicmanager.WithBaseURL(server.URL))
+	path := filepath.Join(t.TempDir(), "export.csv")
+
+	_, err := exportDomainsToFile(client.Domains(ctx, nicmanager.DefaultPageSize), exportOptions{CutoffDate: time.Now()}, path)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoFileExists(t, path, "A canceled export must not leave a file behind")
+}
+
+func TestExportDomainsToFile_KeepsExistingFile(t *testing.T) {
+	// the first page is full, the second one fails
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var domains []nicmanager.Domain
+		for i := 0; i < nicmanager.DefaultPageSize; i++ {
+			domains = append(domains, nicmanager.Domain{Name: fmt.Sprintf("domain%03d.com", i)})
+		}
+		json.NewEncoder(w).Encode(domains)
+	}))
+	defer server.Close()
+
+	client := nicmanager.NewClient("testuser", "testpass", nicmanager.WithBaseURL(server.URL))
+	path := filepath.Join(t.TempDir(), "export.csv")
+	require.NoError(t, os.WriteFile(path, []byte("previous export\n"), 0o644))
+
+	result, err := exportDomainsToFile(client.Domains(context.Background(), nicmanager.DefaultPageSize), exportOptions{CutoffDate: time.Now(), KeepPartial: true}, path)
+	require.Error(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "previous export\n", string(content), "A failed export must not touch the existing file")
+
+	assert.Equal(t, path+partialSuffix, result.PartialPath)
+	partial, err := os.ReadFile(result.PartialPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(partial), "domain099.com", "The partial file should contain the first page")
+}
+
+func TestExportDomainsToFile_KeepPartialNotStreamed(t *testing.T) {
+	for _, format := range []outputFormat{formatXLSX, formatODS, formatSQLite} {
+		t.Run(string(format), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("The API must not be called for an export that cannot keep its partial output")
+			}))
+			defer server.Close()
+
+			client := nicmanager.NewClient("testuser", "testpass", nicmanager.WithBaseURL(server.URL))
+			path := filepath.Join(t.TempDir(), "export."+string(format))
+
+			_, err := exportDomainsToFile(client.Domains(context.Background(), nicmanager.DefaultPageSize), exportOptions{CutoffDate: time.Now(), Format: format, KeepPartial: true}, path)
+			assert.ErrorContains(t, err, "cannot be kept")
+			assert.NoFileExists(t, path)
+			assert.NoFileExists(t, path+partialSuffix)
+		})
+	}
+}
+
+func TestExportDomainsToFile_NewSQLiteDatabase(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := nicmanager.NewClient("testuser", "wrongpass", nicmanager.WithBaseURL(server.URL))
+	dir := t.TempDir()
+
+	_, err := exportDomainsToFile(client.Domains(context.Background(), nicmanager.DefaultPageSize), exportOptions{CutoffDate: time.Now(), Format: formatSQLite}, filepath.Join(dir, "export.sqlite"))
+	assert.Error(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "A failed export must not leave a new database behind")
+}
+
+func TestExportDomainsToFile_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := nicmanager.NewClient("testuser", "wrongpass", nicmanager.WithBaseURL(server.URL))
+	path := filepath.Join(t.TempDir(), "export.csv")
+
+	_, err := exportDomainsToFile(client.Domains(context.Background(), nicmanager.DefaultPageSize), exportOptions{CutoffDate: time.Now()}, path)
+	assert.Error(t, err)
+	assert.NoFileExists(t, path, "A failed export must not leave a file behind")
+}
+
+func TestCheckOutputPath(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.csv")
+	require.NoError(t, os.WriteFile(existing, []byte("keep"), 0o600))
+
+	assert.NoError(t, checkOutputPath(filepath.Join(dir, "new.csv")))
+	assert.NoError(t, checkOutputPath(existing))
+	assert.ErrorContains(t, checkOutputPath(dir), "is a directory")
+	assert.Error(t, checkOutputPath(filepath.Join(dir, "missing", "export.csv")))
+
+	content, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "keep", string(content), "The check must not truncate an existing file")
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "The check must not leave files behind")
+}
+
+func TestExportDomains_UnreadableDates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"name":"fraction.com","order_datetime":"2022-01-01T10:00:00.123Z","registration_datetime":"2022-01-02T00:00:00+02:00"},
+			{"name":"broken.com","order_datetime":"01.01.2022","registration_datetime":"2022-01-02T00:00:00Z","delete_datetime":"soon"}
+		]`))
+	}))
+	defer server.Close()
+
+	client := nicmanager.NewClient("testuser", "testpass", nicmanager.WithBaseURL(server.URL))
+	cutoffDate := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("lenient mode reports and exports empty cells", func(t *testing.T) {
+		var out bytes.Buffer
+		result, err := exportDomains(client.Domains(context.Background(), nicmanager.DefaultPageSize), exportOptions{CutoffDate: cutoffDate}, &out)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.RecordsWritten, "A record with an unreadable delete date must not be dropped")
+
+		require.Len(t, result.Issues, 2)
+		assert.Equal(t, "broken.com", result.Issues[0].Domain)
+		assert.Equal(t, "order_datetime", result.Issues[0].Field)
+		assert.Equal(t, "01.01.2022", result.Issues[0].Value)
+		assert.Equal(t, "delete_datetime", result.Issues[1].Field)
+
+		assert.Equal(t, "Domain,Order Date,Reg Date,Close Date\n"+
+			"fraction.com,2022-01-01,2022-01-01,\n"+
+			"broken.com,,2022-01-02,\n", out.String())
+	})
+
+	t.Run("strict mode fails", func(t *testing.T) {
+		var out bytes.Buffer
+		_, err := exportDomains(client.Domains(context.Background(), nicmanager.DefaultPageSize), exportOptions{CutoffDate: cutoffDate, Strict: true}, &out)
+
+		var dateErr *DateError
+		require.ErrorAs(t, err, &dateErr)
+		assert.Equal(t, "broken.com", dateErr.Domain)
+		assert.Equal(t, exitData, exitCode(err))
+	})
+}
+
+func TestExportDomains_ExtraFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name":"example.com","order_status":"active","auth_code_status":"locked","nameservers":["ns1.example.net"]}]`))
+	}))
+	defer server.Close()
+
+	client := nicmanager.NewClient("testuser", "testpass", nicmanager.WithBaseURL(server.URL))
+	columns, err := parseColumns("name,extra.auth_code_status=Auth Code,extra.nameservers")
+	require.NoError(t, err)
+
+	var csvOut bytes.Buffer
+	_, err = exportDomains(client.Domains(context.Background(), nicmanager.DefaultPageSize), exportOptions{CutoffDate: time.Now(), Columns: columns}, &csvOut)
+	require.NoError(t, err)
+	assert.Equal(t, "Domain,Auth Code,nameservers\nexample.com,locked,\"[\"\"ns1.example.net\"\"]\"\n", csvOut.String())
+
+	var ndjsonOut bytes.Buffer
+	_, err = exportDomains(client.Domains(context.Background(), nicmanager.DefaultPageSize), exportOptions{CutoffDate: time.Now(), Format: formatNDJSON}, &ndjsonOut)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"example.com","order_status":"active","order_datetime":"","registration_datetime":"","delete_datetime":"","auth_code_status":"locked","nameservers":["ns1.example.net"]}`, ndjsonOut.String())
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// inventoryMode names the rule that decides which domains belong to the
+// inventory of an export
+type inventoryMode string
+
+const (
+	// modeCutoff keeps every domain not deleted by the cutoff date, including
+	// domains ordered later; this is what the export always did
+	modeCutoff inventoryMode = "cutoff"
+	// modeAsOf is the portfolio at the cutoff date: registered or ordered by
+	// then and not yet deleted, see Domain.IsInPortfolioAt
+	modeAsOf inventoryMode = "as-of"
+	// modeBetween keeps every domain that was in the portfolio at any point
+	// from the start date to the cutoff date, see Domain.IsActiveBetween
+	modeBetween inventoryMode = "between"
+)
+
+// inventoryModes lists the modes in the order they are offered to the user
+var inventoryModes = []inventoryMode{modeCutoff, modeAsOf, modeBetween}
+
+// parseInventoryMode parses a mode name, case insensitively
+func parseInventoryMode(name string) (inventoryMode, error) {
+	for _, mode := range inventoryModes {
+		if strings.EqualFold(name, string(mode)) {
+			return mode, nil
+		}
+	}
+	names := make([]string, len(inventoryModes))
+	for i, mode := range inventoryModes {
+		names[i] = string(mode)
+	}
+	return "", fmt.Errorf("unknown mode %q, available modes: %s", name, strings.Join(names, ", "))
+}
+
+// parseStartDate parses the start date of the between mode in the form
+// YYYY-MM-DD, the returned instant is the first one of that day in loc
+func parseStartDate(value string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
+
+// inInventory reports whether d belongs to the inventory selected by mode,
+// the empty mode is modeCutoff. start is only used by modeBetween.
+func (mode inventoryMode) inInventory(d *Domain, start time.Time, cutoff time.Time) bool {
+	switch mode {
+	case modeAsOf:
+		return d.IsInPortfolioAt(cutoff)
+	case modeBetween:
+		return d.IsActiveBetween(start, cutoff)
+	default:
+		return d.IsBelowCutoff(cutoff)
+	}
+}
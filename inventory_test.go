@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInventoryMode(t *testing.T) {
+	for _, mode := range inventoryModes {
+		parsed, err := parseInventoryMode(string(mode))
+		require.NoError(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+
+	parsed, err := parseInventoryMode("AS-OF")
+	require.NoError(t, err)
+	assert.Equal(t, modeAsOf, parsed)
+
+	_, err = parseInventoryMode("latest")
+	assert.EqualError(t, err, `unknown mode "latest", available modes: cutoff, as-of, between`)
+}
+
+func TestInventoryMode_InInventory(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cutoff := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// registered after the cutoff, deleted before the start, deleted in the range
+	late := Domain{Name: "late.com", RegistrationDateTime: "2023-07-01T00:00:00Z"}
+	gone := Domain{Name: "gone.com", RegistrationDateTime: "2020-01-01T00:00:00Z", DeleteDateTime: "2022-12-01T00:00:00Z"}
+	left := Domain{Name: "left.com", RegistrationDateTime: "2020-01-01T00:00:00Z", DeleteDateTime: "2023-03-01T00:00:00Z"}
+
+	tests := []struct {
+		mode     inventoryMode
+		expected []bool
+	}{
+		{"", []bool{true, false, false}},
+		{modeCutoff, []bool{true, false, false}},
+		{modeAsOf, []bool{false, false, false}},
+		{modeBetween, []bool{false, false, true}},
+	}
+
+	for _, tt := range tests {
+		for i, domain := range []Domain{late, gone, left} {
+			assert.Equal(t, tt.expected[i], tt.mode.inInventory(&domain, start, cutoff), "mode %q, domain %s", tt.mode, domain.Name)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+//go:build darwin
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityNotFound is the exit code of the security command for a missing
+// keychain item
+const securityNotFound = 44
+
+// macKeychain keeps the passwords in the login keychain through the security
+// command
+type macKeychain struct{}
+
+// newKeyring returns the macOS keychain
+func newKeyring() (credentialStore, error) {
+	if _, err := exec.LookPath("security"); err != nil {
+		return nil, fmt.Errorf("%w: %v", errKeyringUnavailable, err)
+	}
+	return macKeychain{}, nil
+}
+
+func (macKeychain) name() string {
+	return "macOS keychain"
+}
+
+func (macKeychain) lookup(login string) (string, error) {
+	output, err := exec.Command("security", "find-generic-password", "-s", credentialService, "-a", login, "-w").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityNotFound {
+		return "", errNoCredential
+	}
+	if err != nil {
+		return "", fmt.Errorf("keychain: %w", err)
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+func (macKeychain) store(login string, password string) error {
+	// the interactive mode reads the command from stdin, so the password
+	// never shows up in the process list
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		securityQuote(credentialService), securityQuote(login), securityQuote(password)))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("keychain: %w", err)
+	}
+	// errors of the interactive mode do not change the exit code
+	if message := strings.TrimSpace(string(output)); message != "" {
+		return fmt.Errorf("keychain: %s", strings.ReplaceAll(message, password, "***"))
+	}
+	return nil
+}
+
+func (macKeychain) remove(login string) error {
+	err := exec.Command("security", "delete-generic-password", "-s", credentialService, "-a", login).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityNotFound {
+		return errNoCredential
+	}
+	if err != nil {
+		return fmt.Errorf("keychain: %w", err)
+	}
+	return nil
+}
+
+// securityQuote quotes an argument for the interactive mode of security
+func securityQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
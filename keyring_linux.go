@@ -0,0 +1,68 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretToolKeyring keeps the passwords in the Secret Service (GNOME
+// Keyring, KWallet) through the secret-tool command of libsecret
+type secretToolKeyring struct {
+	path string
+}
+
+// newKeyring returns the Secret Service keyring if secret-tool is installed
+func newKeyring() (credentialStore, error) {
+	path, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil, fmt.Errorf("%w: secret-tool is not installed", errKeyringUnavailable)
+	}
+	return &secretToolKeyring{path: path}, nil
+}
+
+func (k *secretToolKeyring) name() string {
+	return "Secret Service keyring"
+}
+
+// attributes identify the password of login
+func (k *secretToolKeyring) attributes(login string) []string {
+	return []string{"service", credentialService, "login", login}
+}
+
+func (k *secretToolKeyring) lookup(login string) (string, error) {
+	output, err := exec.Command(k.path, append([]string{"lookup"}, k.attributes(login)...)...).Output()
+	// secret-tool fails without output if nothing is stored
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(output) == 0 && len(exitErr.Stderr) == 0 {
+		return "", errNoCredential
+	}
+	if err != nil {
+		return "", fmt.Errorf("secret-tool lookup: %w", err)
+	}
+	return string(output), nil
+}
+
+func (k *secretToolKeyring) store(login string, password string) error {
+	args := append([]string{"store", "--label", credentialService + " " + login}, k.attributes(login)...)
+	cmd := exec.Command(k.path, args...)
+	// the password is read from stdin, never passed as argument
+	cmd.Stdin = strings.NewReader(password)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool store: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (k *secretToolKeyring) remove(login string) error {
+	if _, err := k.lookup(login); err != nil {
+		return err
+	}
+	if output, err := exec.Command(k.path, append([]string{"clear"}, k.attributes(login)...)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool clear: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+// newKeyring reports that this system has no supported keyring
+func newKeyring() (credentialStore, error) {
+	return nil, errKeyringUnavailable
+}
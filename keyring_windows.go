@@ -0,0 +1,119 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Windows Credential Manager API, see wincred.h
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// winCredential is CREDENTIALW
+type winCredential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// windowsCredentialManager keeps the passwords as generic credentials in
+// the Windows Credential Manager
+type windowsCredentialManager struct{}
+
+// newKeyring returns the Windows Credential Manager
+func newKeyring() (credentialStore, error) {
+	if err := advapi32.Load(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errKeyringUnavailable, err)
+	}
+	return windowsCredentialManager{}, nil
+}
+
+func (windowsCredentialManager) name() string {
+	return "Windows Credential Manager"
+}
+
+// target names the credential of login
+func (windowsCredentialManager) target(login string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(credentialService + ":" + login)
+}
+
+func (m windowsCredentialManager) lookup(login string) (string, error) {
+	target, err := m.target(login)
+	if err != nil {
+		return "", err
+	}
+	var cred *winCredential
+	ok, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ok == 0 {
+		if errors.Is(err, errorNotFound) {
+			return "", errNoCredential
+		}
+		return "", fmt.Errorf("credential manager: %w", err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func (m windowsCredentialManager) store(login string, password string) error {
+	target, err := m.target(login)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(login)
+	if err != nil {
+		return err
+	}
+	blob := []byte(password)
+	cred := winCredential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if ok, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); ok == 0 {
+		return fmt.Errorf("credential manager: %w", err)
+	}
+	return nil
+}
+
+func (m windowsCredentialManager) remove(login string) error {
+	target, err := m.target(login)
+	if err != nil {
+		return err
+	}
+	if ok, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); ok == 0 {
+		if errors.Is(err, errorNotFound) {
+			return errNoCredential
+		}
+		return fmt.Errorf("credential manager: %w", err)
+	}
+	return nil
+}
@@ -1,16 +1,19 @@
-//go:build !test
+//go:build !test && !nogui
 
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"image/color"
-	"io"
 	"log"
-	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -18,12 +21,25 @@ import (
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/mariow/nicmanager-export/nicmanager"
 )
 
 func main() {
+	// any argument selects the headless command line mode
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
+	runGUI()
+}
+
+func runGUI() {
 	a := app.NewWithID("witte.io.nicmanager-export")
 	w := a.NewWindow("Nicmanager Exporter") // main app name shown in process list
 
@@ -41,20 +57,313 @@ func main() {
 	   Progress
 	*/
 
+	// config file and snapshot store, the GUI works without both
+	config, configErr := loadConfig("")
+	store, storeErr := openSnapshotStore(config.Snapshots.Dir)
+
+	// source of the domains: the API, or a stored snapshot which needs no
+	// credentials
+	var sourceSnapshots []snapshotInfo
+	uiSource := widget.NewSelect(nil, nil)
+	refreshSources := func() {
+		options := []string{"Nicmanager API"}
+		sourceSnapshots = nil
+		if store != nil {
+			sourceSnapshots, _ = store.list()
+		}
+		for _, snap := range sourceSnapshots {
+			options = append(options, "Snapshot vom "+snap.Time.Local().Format("02.01.2006 15:04:05"))
+		}
+		uiSource.SetOptions(options)
+		uiSource.SetSelectedIndex(0)
+	}
+	fromSnapshot := func() bool {
+		return uiSource.SelectedIndex() > 0
+	}
+
 	// TODO: Validator in separate Funktionen auslagern
 	uiCredUsername := widget.NewEntry()
 	uiCredUsername.SetPlaceHolder("account.user")
-	uiCredUsername.Validator = validation.NewRegexp("^[a-z0-9_.-]+$", "Darf nicht leer sein")
+	usernameValidator := validation.NewRegexp("^[a-z0-9_.-]+$", "Darf nicht leer sein")
+	uiCredUsername.Validator = func(text string) error {
+		if fromSnapshot() {
+			return nil
+		}
+		return usernameValidator(text)
+	}
 	uiCredPassword := widget.NewPasswordEntry()
 	uiCredPassword.SetPlaceHolder("supergeheim")
-	uiCredPassword.Validator = validation.NewRegexp("^.+$", "Darf nicht leer sein")
+	passwordValidator := validation.NewRegexp("^.+$", "Darf nicht leer sein")
+	uiCredPassword.Validator = func(text string) error {
+		if fromSnapshot() {
+			return nil
+		}
+		return passwordValidator(text)
+	}
+	uiSource.OnChanged = func(string) {
+		uiCredUsername.Validate()
+		uiCredPassword.Validate()
+	}
+	uiSavePassword := widget.NewCheck("Passwort speichern", nil)
+
+	// the credential store keeps the password between starts; it is used
+	// outside of the UI goroutine, as the keyring runs commands and the
+	// encrypted file asks for its passphrase in a dialog until it was
+	// entered once
+	var credMu sync.Mutex
+	askPassphrase := func() (string, error) {
+		answer := make(chan string, 1)
+		fyne.Do(func() {
+			entry := widget.NewPasswordEntry()
+			dialog.ShowForm("Passwortspeicher entsperren", "Entsperren", "Abbrechen",
+				[]*widget.FormItem{{Text: "Passphrase", Widget: entry}},
+				func(ok bool) {
+					if !ok {
+						entry.SetText("")
+					}
+					answer <- entry.Text
+				}, w)
+		})
+		passphrase := <-answer
+		if passphrase == "" {
+			return "", errors.New("keine Passphrase für den Passwortspeicher eingegeben")
+		}
+		return passphrase, nil
+	}
+	credStore, credErr := openCredentialStore(config.Credentials, askPassphrase)
+	if credStore == nil {
+		uiSavePassword.Hide()
+	}
+	refreshSources()
 	uiCutoffDate := widget.NewEntry()
 	uiCutoffDate.SetPlaceHolder("2020-03-01")
 	//TODO Validation mit Regex plus time.Parse bauen
 	uiCutoffDate.Validator = validation.NewRegexp("^20[0-9]{2}-[0-9]{2}-[0-9]{2}$", "Datum muss das Format YYYY-MM-DD haben")
+
+	// the German names of inventoryModes, in the same order; the range of
+	// the between mode starts at the from date and ends at the cutoff date
+	modeNames := []string{"Nicht gelöscht am Stichtag", "Bestand am Stichtag", "Aktiv im Zeitraum"}
+	uiFromDate := widget.NewEntry()
+	uiFromDate.SetPlaceHolder("Beginn, z.B. 2019-01-01")
+	uiFromDate.Hide()
+	var uiMode *widget.Select
+	uiMode = widget.NewSelect(modeNames, func(string) {
+		if inventoryModes[uiMode.SelectedIndex()] == modeBetween {
+			uiFromDate.Show()
+		} else {
+			uiFromDate.Hide()
+		}
+	})
+	uiMode.SetSelectedIndex(0)
+
+	// the target file is typed as path, its folder can be chosen in a dialog
 	uiFilename := widget.NewEntry()
 	uiFilename.SetPlaceHolder("Export_12345.csv")
-	uiFilename.Validator = validation.NewRegexp("^[a-zA-Z0-9_ -]+.csv$", "Der Dateiname muss auf .csv enden und die Datei darf noch nicht existieren")
+	uiFilename.Validator = func(path string) error {
+		if _, ok := formatFromPath(path); !ok {
+			return errors.New("Der Dateiname muss auf .csv, .xlsx, .ods, .json, .ndjson oder .sqlite enden")
+		}
+		return nil
+	}
+	uiChooseFile := widget.NewButton("Auswählen…", func() {
+		showExportFileDialog(a, w, uiFilename)
+	})
+
+	formatNames := make([]string, len(outputFormats))
+	for i, format := range outputFormats {
+		formatNames[i] = string(format)
+	}
+	uiFormat := widget.NewSelect(formatNames, nil)
+	uiFormat.SetSelected(string(formatCSV))
+	// the format follows the extension of the file name if it names a
+	// format, and the other way round, so both always match
+	uiFilename.OnChanged = func(name string) {
+		if format, ok := formatFromPath(name); ok && string(format) != uiFormat.Selected {
+			uiFormat.SetSelected(string(format))
+		}
+	}
+	uiFormat.OnChanged = func(selected string) {
+		if format, ok := formatFromPath(uiFilename.Text); ok && string(format) != selected {
+			uiFilename.SetText(strings.TrimSuffix(uiFilename.Text, filepath.Ext(uiFilename.Text)) + "." + selected)
+		}
+	}
+
+	uiTimezone := widget.NewSelectEntry([]string{"Local", "UTC", "Europe/Berlin"})
+	uiTimezone.SetText("Local")
+	uiTimezone.Validator = func(name string) error {
+		if _, err := time.LoadLocation(name); err != nil {
+			return errors.New("Unbekannte Zeitzone")
+		}
+		return nil
+	}
+	uiStrict := widget.NewCheck("Bei unlesbarem Datum abbrechen", nil)
+	uiKeepPartial := widget.NewCheck("Unvollständige Datei als .partial behalten", nil)
+
+	// columns of CSV, XLSX and ODS, preselected and labeled from the
+	// profile; the order in which they are ticked is the column order
+	var configColumns []column
+	uiColumns := widget.NewCheckGroup(nil, nil)
+	uiColumnsAccordion := widget.NewAccordion(widget.NewAccordionItem("Auswahl und Reihenfolge", uiColumns))
+
+	// CSV dialect presets, the details of the profile apply on top
+	uiCSVPreset := widget.NewSelect(csvPresetNames, nil)
+
+	// filters in an advanced section, prefilled from the profile
+	newFilterEntry := func(placeholder string) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder(placeholder)
+		return entry
+	}
+	uiFilterTLDs := newFilterEntry("com, net")
+	uiFilterNames := newFilterEntry("*shop*.de")
+	uiFilterRegex := newFilterEntry(`^[a-z]+\.com$`)
+	uiFilterStatuses := newFilterEntry("active")
+	uiFilterOrdered := newFilterEntry("2019-01-01..2020-12-31")
+	uiFilterRegistered := newFilterEntry("2019-01-01..")
+	uiFilterDeleted := newFilterEntry("..2024-12-31")
+	uiFilterAccordion := widget.NewAccordion(widget.NewAccordionItem("Erweitert", container.New(layout.NewFormLayout(),
+		widget.NewLabel("TLDs"), uiFilterTLDs,
+		widget.NewLabel("Name (Muster)"), uiFilterNames,
+		widget.NewLabel("Name (Regex)"), uiFilterRegex,
+		widget.NewLabel("Status"), uiFilterStatuses,
+		widget.NewLabel("Bestellt"), uiFilterOrdered,
+		widget.NewLabel("Registriert"), uiFilterRegistered,
+		widget.NewLabel("Gelöscht"), uiFilterDeleted,
+	)))
+
+	// settings of the selected profile of the config file, applied to the
+	// form whenever another profile is selected
+	var settings profileSettings
+
+	// loadPassword fills in an empty password from the password source of
+	// the profile, else from the credential store
+	loadPassword := func() {
+		login, source := uiCredUsername.Text, settings.passwordSource()
+		if login == "" || uiCredPassword.Text != "" {
+			return
+		}
+		go func() {
+			password, ok, err := source.password(context.Background())
+			stored := false
+			if !ok && err == nil && credStore != nil {
+				credMu.Lock()
+				password, err = credStore.lookup(login)
+				credMu.Unlock()
+				stored = err == nil
+			}
+			if err != nil && !errors.Is(err, errNoCredential) {
+				log.Println(err)
+			}
+			if password == "" {
+				return
+			}
+			fyne.Do(func() {
+				if uiCredUsername.Text == login && uiCredPassword.Text == "" {
+					uiCredPassword.SetText(password)
+					uiSavePassword.SetChecked(stored)
+				}
+			})
+		}()
+	}
+	uiCredUsername.OnSubmitted = func(string) {
+		loadPassword()
+	}
+	applySettings := func(selected profileSettings) {
+		settings = selected
+
+		// a profile without login keeps the one typed in
+		if settings.Login != "" && uiCredUsername.Text != settings.Login {
+			uiCredUsername.SetText(settings.Login)
+			uiCredPassword.SetText("")
+		}
+		loadPassword()
+		uiTimezone.SetText(cmp.Or(settings.TimeZone, "Local"))
+		uiMode.SetSelectedIndex(0)
+		if mode, err := parseInventoryMode(settings.Mode); err == nil {
+			uiMode.SetSelectedIndex(slices.Index(inventoryModes, mode))
+		}
+		format := formatCSV
+		if parsed, err := parseOutputFormat(settings.Format); err == nil && settings.Format != "" {
+			format = parsed
+		}
+		uiFormat.SetSelected(string(format))
+
+		// the file keeps its name, in the output directory and with the
+		// extension of the profile
+		if settings.OutputDir != "" || (uiFilename.Text != "" && settings.Format != "") {
+			name := "Export_" + time.Now().Format("2006-01-02") + "." + string(format)
+			if uiFilename.Text != "" {
+				name = filepath.Base(uiFilename.Text)
+				if settings.Format != "" {
+					name = strings.TrimSuffix(name, filepath.Ext(name)) + "." + string(format)
+				}
+			}
+			if settings.OutputDir == "" {
+				name = filepath.Join(filepath.Dir(uiFilename.Text), name)
+			}
+			if path, err := settings.outputPath(name); err == nil {
+				uiFilename.SetText(path)
+			}
+		}
+
+		var colErr error
+		if configColumns, colErr = settings.columns(); colErr != nil {
+			dialog.ShowError(fmt.Errorf("Konfigurationsdatei fehlerhaft: %w", colErr), w)
+		}
+		preselected := configColumns
+		if preselected == nil {
+			preselected = defaultColumns
+		}
+		var columnNames, selectedLabels []string
+		for _, col := range selectableColumns(configColumns) {
+			columnNames = append(columnNames, col.Label)
+		}
+		for _, col := range preselected {
+			def, _ := lookupColumn(col.Key)
+			selectedLabels = append(selectedLabels, def.Label)
+		}
+		uiColumns.Options = columnNames
+		uiColumns.SetSelected(selectedLabels)
+
+		uiCSVPreset.SetSelected(csvPresetNames[0])
+		for _, name := range csvPresetNames {
+			if settings.CSV.Preset != "" && normalizePresetName(name) == normalizePresetName(settings.CSV.Preset) {
+				uiCSVPreset.SetSelected(name)
+			}
+		}
+
+		uiFilterTLDs.SetText(strings.Join(settings.Filter.TLDs, ", "))
+		uiFilterNames.SetText(strings.Join(settings.Filter.Names, ", "))
+		uiFilterRegex.SetText(settings.Filter.NameRegex)
+		uiFilterStatuses.SetText(strings.Join(settings.Filter.Statuses, ", "))
+		uiFilterOrdered.SetText(settings.Filter.Ordered)
+		uiFilterRegistered.SetText(settings.Filter.Registered)
+		uiFilterDeleted.SetText(settings.Filter.Deleted)
+	}
+
+	// the first entry are the top-level settings only
+	noProfile := "Kein Profil"
+	uiProfile := widget.NewSelect(append([]string{noProfile}, config.profileNames()...), func(name string) {
+		if name == noProfile {
+			applySettings(config.profileSettings.override(settingsFromEnv()))
+			return
+		}
+		selected, err := config.profile(name)
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		applySettings(selected)
+	})
+	if err := errors.Join(configErr, storeErr, credErr); err != nil {
+		dialog.ShowError(fmt.Errorf("Konfigurationsdatei fehlerhaft: %w", err), w)
+	}
+	// $NICMANAGER_PROFILE preselects a profile
+	if name := os.Getenv(profileEnvVar); name != "" && slices.Contains(uiProfile.Options, name) {
+		uiProfile.SetSelected(name)
+	} else {
+		uiProfile.SetSelected(noProfile)
+	}
 
 	obscureProgress := widget.NewProgressBarInfinite()
 	obscureProgress.Hide()
@@ -62,60 +371,236 @@ func main() {
 	statusMessage := canvas.NewText("", theme.TextColor())
 	statusMessage.Hide()
 
-	var uiForm = &widget.Form{
+	// cancelExport stops the running export, nil while none is running
+	var cancelExport context.CancelFunc
+
+	uiCancel := widget.NewButton("Abbrechen", func() {
+		if cancelExport != nil {
+			cancelExport()
+		}
+	})
+	uiCancel.Hide()
+
+	var startExport func(filename string, opts exportOptions)
+	var uiForm *widget.Form
+	uiForm = &widget.Form{
 		Items: []*widget.FormItem{
+			{Text: "Profil", Widget: uiProfile},
+			{Text: "Quelle", Widget: uiSource},
 			{Text: "Benutzer", Widget: uiCredUsername},
-			{Text: "Passwort", Widget: uiCredPassword},
+			{Text: "Passwort", Widget: container.NewVBox(uiCredPassword, uiSavePassword)},
 			{Text: "Stichtag", Widget: uiCutoffDate},
-			{Text: "Zieldatei", Widget: uiFilename},
+			{Text: "Modus", Widget: container.NewVBox(uiMode, uiFromDate)},
+			{Text: "Zeitzone", Widget: uiTimezone},
+			{Text: "Zieldatei", Widget: container.NewBorder(nil, nil, nil, uiChooseFile, uiFilename)},
+			{Text: "Format", Widget: uiFormat},
+			{Text: "Spalten", Widget: uiColumnsAccordion},
+			{Text: "CSV-Format", Widget: uiCSVPreset},
+			{Text: "Filter", Widget: uiFilterAccordion},
+			{Text: "Bei Fehlern", Widget: container.NewVBox(uiStrict, uiKeepPartial)},
 		},
 		OnSubmit: func() {
-			// show progressbar
-			obscureProgress.Show()
-
-			// open output file
-			// TODO: more checks needed
-			outFile, fErr := os.Create(uiFilename.Text)
-			if fErr != nil {
-				log.Fatal(fErr)
+			loc, tzErr := time.LoadLocation(uiTimezone.Text)
+			if tzErr != nil {
+				dialog.ShowError(fmt.Errorf("Unbekannte Zeitzone %q", uiTimezone.Text), w)
+				return
 			}
-			defer outFile.Close()
 
-			// TODO: Korrekterweise sollte das Datum immer mit 23:59:59 geparst werden
-			cutoffDate, dtErr := time.Parse("2006-01-02", uiCutoffDate.Text)
+			// the cutoff covers the whole day in the selected time zone
+			cutoffDate, dtErr := parseCutoffDate(uiCutoffDate.Text, loc)
 			if dtErr != nil {
-				log.Fatal(dtErr)
+				dialog.ShowError(fmt.Errorf("Ungültiger Stichtag %q: %w", uiCutoffDate.Text, dtErr), w)
+				return
 			}
 
-			// fetch data from API and write to output file
-			recordsWritten, err := fetchAndWrite(
-				uiCredUsername.Text,
-				uiCredPassword.Text,
-				cutoffDate,
-				outFile,
-			)
+			mode := inventoryModes[uiMode.SelectedIndex()]
+			var startDate time.Time
+			if mode == modeBetween {
+				var fromErr error
+				if startDate, fromErr = parseStartDate(uiFromDate.Text, loc); fromErr != nil {
+					dialog.ShowError(fmt.Errorf("Ungültiger Beginn des Zeitraums %q, erwartet wird YYYY-MM-DD", uiFromDate.Text), w)
+					return
+				}
+				if startDate.After(cutoffDate) {
+					dialog.ShowError(errors.New("Der Zeitraum beginnt nach dem Stichtag"), w)
+					return
+				}
+			}
 
-			if err != nil {
-				log.Fatal(err)
+			if len(uiColumns.Selected) == 0 {
+				dialog.ShowError(errors.New("Bitte mindestens eine Spalte auswählen"), w)
+				return
+			}
+			columns, colErr := columnsByDefaultLabel(uiColumns.Selected, configColumns)
+			if colErr != nil {
+				dialog.ShowError(colErr, w)
+				return
 			}
 
-			statusMessage.Text = fmt.Sprintf("%d Zeilen geschrieben", recordsWritten)
-			statusMessage.Show()
+			dialect, dialectErr := settings.CSV.override(csvSettings{Preset: uiCSVPreset.Selected}).dialect()
+			if dialectErr != nil {
+				dialog.ShowError(fmt.Errorf("Ungültiges CSV-Format: %w", dialectErr), w)
+				return
+			}
 
-			// clear fields to disable submit button
-			uiCutoffDate.SetText("")
+			filter, filterErr := filterSettings{
+				TLDs:       splitList(uiFilterTLDs.Text),
+				Names:      splitList(uiFilterNames.Text),
+				NameRegex:  uiFilterRegex.Text,
+				Statuses:   splitList(uiFilterStatuses.Text),
+				Ordered:    uiFilterOrdered.Text,
+				Registered: uiFilterRegistered.Text,
+				Deleted:    uiFilterDeleted.Text,
+			}.compile(loc)
+			if filterErr != nil {
+				dialog.ShowError(fmt.Errorf("Ungültiger Filter: %w", filterErr), w)
+				return
+			}
 
-			// hide progressbar
-			obscureProgress.Hide()
+			// the target must be writable before the fetch starts; an existing
+			// file is only replaced after asking, SQLite databases are updated
+			filename := uiFilename.Text
+			opts := exportOptions{
+				CutoffDate:  cutoffDate,
+				Mode:        mode,
+				StartDate:   startDate,
+				Location:    loc,
+				Format:      outputFormat(uiFormat.Selected),
+				Columns:     columns,
+				CSV:         dialect,
+				Filter:      filter,
+				Strict:      uiStrict.Checked,
+				KeepPartial: uiKeepPartial.Checked,
+			}
+			if opts.KeepPartial && !opts.Format.streams() {
+				dialog.ShowError(fmt.Errorf("Eine unvollständige Datei kann nur bei CSV, JSON und NDJSON behalten werden, nicht bei %s", opts.Format), w)
+				return
+			}
+			if err := checkOutputPath(filename); err != nil {
+				dialog.ShowError(fmt.Errorf("Die Zieldatei kann nicht geschrieben werden: %w", err), w)
+				return
+			}
+			if _, err := os.Stat(filename); err == nil && opts.Format != formatSQLite {
+				dialog.ShowConfirm("Datei überschreiben?", fmt.Sprintf("Die Datei %s existiert bereits. Soll sie überschrieben werden?", filename), func(ok bool) {
+					if ok {
+						startExport(filename, opts)
+					}
+				}, w)
+				return
+			}
+			startExport(filename, opts)
 		},
 	}
 
+	// startExport runs the export with the validated settings of the form
+	startExport = func(filename string, opts exportOptions) {
+		// show progressbar and lock the form while the export runs
+		obscureProgress.Show()
+		uiCancel.Show()
+		statusMessage.Hide()
+		uiForm.Disable()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelExport = cancel
+
+		// fetch data from API and write to output file, in the background
+		// so the window stays responsive and the export can be canceled;
+		// every fetched list is stored as snapshot
+		login, password := uiCredUsername.Text, uiCredPassword.Text
+		savePassword := uiSavePassword.Checked && credStore != nil && !fromSnapshot()
+		client := newAPIClient(login, password)
+		domains := client.Domains(ctx, nicmanager.DefaultPageSize)
+		if fromSnapshot() {
+			domains = snapshotDomains(sourceSnapshots[uiSource.SelectedIndex()-1].Path)
+		} else if store != nil && !config.Snapshots.Disabled {
+			var recErr error
+			if opts.Snapshot, recErr = store.create(time.Now()); recErr != nil {
+				log.Println(recErr)
+			}
+		}
+		go func() {
+			defer cancel()
+			result, err := exportDomainsToFile(domains, opts, filename)
+			if result.SnapshotErr != nil {
+				log.Println(result.SnapshotErr)
+			}
+			if policy := config.Snapshots.retention(); result.Snapshot.ID != "" && policy.isSet() {
+				if _, pruneErr := store.prune(policy, time.Now()); pruneErr != nil {
+					log.Println(pruneErr)
+				}
+			}
+			// only a password the API accepted is stored
+			if err == nil && savePassword {
+				credMu.Lock()
+				if credErr := credStore.store(login, password); credErr != nil {
+					log.Println(credErr)
+				}
+				credMu.Unlock()
+			}
+
+			fyne.Do(func() {
+				cancelExport = nil
+
+				if errors.Is(err, context.Canceled) {
+					statusMessage.Text = "Export abgebrochen, es wurde keine Datei geschrieben"
+				} else if err != nil {
+					log.Println(err)
+					statusMessage.Text = "Export fehlgeschlagen"
+					dialog.ShowError(errors.New(userMessage(err)), w)
+				} else {
+					statusMessage.Text = fmt.Sprintf("%d Zeilen geschrieben", result.RecordsWritten)
+					if len(result.Issues) > 0 {
+						statusMessage.Text += fmt.Sprintf(", %d unlesbare Datumsangaben", len(result.Issues))
+						dialog.ShowInformation("Unlesbare Datumsangaben", issueReport(result.Issues), w)
+					}
+					if result.Snapshot.ID != "" {
+						statusMessage.Text += ", Snapshot gespeichert"
+						refreshSources()
+					}
+					// clear fields to disable submit button
+					uiCutoffDate.SetText("")
+				}
+				if result.PartialPath != "" {
+					statusMessage.Text += ", unvollständig behalten als " + filepath.Base(result.PartialPath)
+				}
+				statusMessage.Show()
+
+				// hide progressbar
+				obscureProgress.Hide()
+				uiCancel.Hide()
+				uiForm.Enable()
+			})
+		}()
+	}
+
+	uiDiff := widget.NewButton("Exporte vergleichen", func() {
+		showDiffWindow(a, settings, store,
+			func() (string, string) { return uiCredUsername.Text, uiCredPassword.Text },
+			func() *time.Location {
+				if loc, err := time.LoadLocation(uiTimezone.Text); err == nil {
+					return loc
+				}
+				return time.Local
+			},
+		)
+	})
+
+	uiSnapshots := widget.NewButton("Snapshots", func() {
+		if store == nil {
+			dialog.ShowError(fmt.Errorf("Kein Verzeichnis für Snapshots: %w", storeErr), w)
+			return
+		}
+		showSnapshotsWindow(a, store, config.Snapshots.retention(), refreshSources)
+	})
+
 	w.SetContent(container.NewVBox(
 		uiTitle,
 		canvas.NewLine(theme.TextColor()),
 		uiForm,
+		container.NewGridWithColumns(2, uiDiff, uiSnapshots),
 
 		obscureProgress,
+		uiCancel,
 		statusMessage,
 		layout.NewSpacer(),
 		canvas.NewText("© 2021-2025", color.White),
@@ -125,103 +610,44 @@ func main() {
 	w.ShowAndRun()
 }
 
-func fetchAndWrite(login string, password string, cutoffDate time.Time, outFile *os.File) (int, error) {
-
-	// init vars
-	var morePages bool = true
-	var recordsWritten int = 0
-	var headerWritten bool = false
-
-	// contact API
-	client := http.Client{}
-	csvWriter := csv.NewWriter(outFile)
-	defer csvWriter.Flush()
-
-	for pageNo := 1; morePages; pageNo++ {
-		log.Println("requesting pageno " + fmt.Sprintf("%d", pageNo))
-
-		fulldoc, err := fetchNicmanagerAPI(client, login, password, pageNo)
+// lastExportDirKey is the preference holding the folder of the target file
+// chosen last
+const lastExportDirKey = "lastExportDir"
+
+// showExportFileDialog lets the user choose the folder of the target file,
+// starting in the folder chosen last, and puts the path with the file name
+// of entry into entry. A folder dialog is used as the save dialog of Fyne
+// truncates an existing file as soon as it is chosen; the export asks before
+// overwriting instead.
+func showExportFileDialog(a fyne.App, w fyne.Window, entry *widget.Entry) {
+	open := dialog.NewFolderOpen(func(folder fyne.ListableURI, err error) {
 		if err != nil {
-			log.Fatal(err)
+			dialog.ShowError(err, w)
+			return
 		}
-
-		// TODO: this needs to go into a separate function
-		var domainList []Domain
-		jsonErr := json.Unmarshal(fulldoc, &domainList)
-		if jsonErr != nil {
-			log.Fatal(jsonErr)
+		if folder == nil {
+			// canceled
+			return
 		}
-
-		//TODO: Irgendwo hier gehen Daten verloren. Im JSON sind noch Daten drin, hier nicht mehr
-
-		for _, rowData := range domainList {
-			if !headerWritten {
-				csvWriter.Write([]string{
-					"Domain",
-					"Order Date",
-					"Reg Date",
-					"Close Date",
-				})
-				headerWritten = true
-			}
-
-			// parse dates
-			dateOrd, _ := parseAPIdate(rowData.OrderDateTime)
-			dateReg, _ := parseAPIdate(rowData.RegistrationDateTime)
-
-			// format Delete date for output
-			dateDelFmt := ""
-			if rowData.DeleteDateTime != "" {
-				parsedDate, _ := parseAPIdate(rowData.DeleteDateTime)
-				dateDelFmt = parsedDate.Format("2006-01-02")
-			}
-
-			//log.Printf("Dateldel: %s (%s) - Cutoff_Unix: %d", rowData.DeleteDateTime, dateDelFmt, cutoffDate.Unix())
-
-			if rowData.IsBelowCutoff(cutoffDate) {
-				csvWriter.Write([]string{
-					rowData.Name,
-					dateOrd.Format("2006-01-02"),
-					dateReg.Format("2006-01-02"),
-					dateDelFmt,
-				})
-				recordsWritten++
-				log.Println("Written") // DEBUG
-			}
-			log.Println("---") //DEBUG
+		name := "Export_" + time.Now().Format("2006-01-02") + ".csv"
+		if entry.Text != "" {
+			name = filepath.Base(entry.Text)
 		}
-
-		// do we have more pages?
-		morePages = (len(domainList) == 100)
+		a.Preferences().SetString(lastExportDirKey, folder.Path())
+		entry.SetText(filepath.Join(folder.Path(), name))
+	}, w)
+
+	// the folder of a typed absolute path wins over the remembered one
+	dir := a.Preferences().String(lastExportDirKey)
+	if filepath.IsAbs(entry.Text) {
+		dir = filepath.Dir(entry.Text)
 	}
-
-	return recordsWritten, nil
-}
-
-func fetchNicmanagerAPI(client http.Client, login string, password string, pageNo int) ([]byte, error) {
-	var apiURL string = "https://api.nicmanager.com/v1/domains?limit=100&page="
-	req, rErr := http.NewRequest("GET", apiURL+fmt.Sprintf("%d", pageNo), nil)
-	req.Header.Add("Accept", "application/json")
-	req.SetBasicAuth(login, password)
-	if rErr != nil {
-		log.Fatal(rErr)
-	}
-
-	res, err := client.Do(req)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		log.Fatalf("status code error: %d %s", res.StatusCode, res.Status)
+	if dir != "" {
+		if location, err := storage.ListerForURI(storage.NewFileURI(dir)); err == nil {
+			open.SetLocation(location)
+		}
 	}
-
-	// DEBUG remove later
-	//fmt.Println("Header output:")
-	//spew.Dump(res.Header)
-
-	// convert response into string
-	return io.ReadAll(res.Body)
-
+	open.Show()
+	// the dialog is drawn inside the narrow main window, grow it to fit
+	w.Resize(w.Canvas().Size().Max(open.MinSize()))
 }
@@ -0,0 +1,192 @@
+// Package nicmanager is a small client for the Nicmanager REST API
+// (https://api.nicmanager.com/docs/v1/).
+package nicmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultBaseURL is the production endpoint of the API
+	DefaultBaseURL = "https://api.nicmanager.com/v1"
+	// DefaultPageSize is the largest page size the API hands out
+	DefaultPageSize = 100
+	// DefaultUserAgent is sent unless WithUserAgent is used
+	DefaultUserAgent = "nicmanager-export"
+)
+
+// Client talks to the Nicmanager API on behalf of one API user
+type Client struct {
+	baseURL     string
+	login       string
+	password    string
+	httpClient  *http.Client
+	userAgent   string
+	retry       RetryPolicy
+	limiter     *rate.Limiter
+	concurrency int
+	logger      *log.Logger
+
+	// sleep waits between retries, replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithBaseURL points the client to another API endpoint, e.g. a test server
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithHTTPClient replaces the default http.Client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithUserAgent sets the User-Agent header of all requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithLogger enables debug logging of requests, retries and rate limiting
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// NewClient creates a client using basic auth with the given API credentials
+func NewClient(login string, password string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:     DefaultBaseURL,
+		login:       login,
+		password:    password,
+		httpClient:  &http.Client{},
+		userAgent:   DefaultUserAgent,
+		retry:       DefaultRetryPolicy,
+		concurrency: 1,
+		sleep:       sleepContext,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ListDomains fetches one page of the domain list, pages start at 1
+func (c *Client) ListDomains(ctx context.Context, page int, limit int) ([]Domain, error) {
+	domainPage, err := c.ListDomainsPage(ctx, page, limit)
+	if err != nil {
+		return nil, err
+	}
+	return domainPage.Domains, nil
+}
+
+// get requests path below the base URL and decodes the JSON response into v,
+// the response headers are returned for pagination metadata
+func (c *Client) get(ctx context.Context, path string, query url.Values, v any) (http.Header, error) {
+	reqURL := c.baseURL + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	res, body, err := c.doWithRetry(ctx, http.MethodGet, reqURL)
+	if err != nil {
+		return nil, fmt.Errorf("nicmanager: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, newStatusError(res, body)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, &DecodeError{Path: path, Err: err}
+	}
+	return res.Header, nil
+}
+
+// doWithRetry sends the request until it either succeeds, fails permanently
+// or the attempts of the retry policy are used up
+func (c *Client) doWithRetry(ctx context.Context, method string, reqURL string) (*http.Response, []byte, error) {
+	for attempt := 1; ; attempt++ {
+		if err := c.waitForRateLimit(ctx); err != nil {
+			return nil, nil, err
+		}
+
+		res, body, err := c.do(ctx, method, reqURL)
+
+		var header http.Header
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// canceled by the caller, not a network problem
+			return nil, nil, err
+		case err != nil:
+		case c.retry.retryStatus(res.StatusCode):
+			header = res.Header
+		default:
+			return res, body, nil
+		}
+
+		if attempt >= c.retry.MaxAttempts {
+			return res, body, err
+		}
+
+		delay := c.retry.backoff(attempt, header, time.Now())
+		if err != nil {
+			c.logf("attempt %d of %s failed: %v, retrying in %s", attempt, reqURL, err, delay)
+		} else {
+			c.logf("attempt %d of %s failed: %s, retrying in %s", attempt, reqURL, res.Status, delay)
+		}
+		if sleepErr := c.sleep(ctx, delay); sleepErr != nil {
+			return nil, nil, sleepErr
+		}
+	}
+}
+
+// do sends a single request and reads the complete response body
+func (c *Client) do(ctx context.Context, method string, reqURL string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	req.SetBasicAuth(c.login, c.password)
+
+	c.logf("%s %s", method, reqURL)
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading response of %s: %w", req.URL.Path, err)
+	}
+	return res, body, nil
+}
+
+// logf writes to the debug logger if one is configured
+func (c *Client) logf(format string, args ...any) {
+	if c.logger != nil {
+		c.logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,148 @@
+package nicmanager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ListDomains(t *testing.T) {
+	tests := []struct {
+		name           string
+		responseBody   string
+		responseStatus int
+		expected       []Domain
+		expectedError  bool
+		login          string
+		password       string
+		pageNo         int
+	}{
+		{
+			name:           "successful API call",
+			responseBody:   `[{"name":"example.com","order_status":"active","order_datetime":"2023-01-01T00:00:00Z","registration_datetime":"2023-01-01T00:00:00Z","delete_datetime":""}]`,
+			responseStatus: 200,
+			expected: []Domain{
+				{
+					Name:                 "example.com",
+					OrderStatus:          "active",
+					OrderDateTime:        "2023-01-01T00:00:00Z",
+					RegistrationDateTime: "2023-01-01T00:00:00Z",
+				},
+			},
+			expectedError: false,
+			login:         "testuser",
+			password:      "testpass",
+			pageNo:        1,
+		},
+		{
+			name:           "empty response",
+			responseBody:   `[]`,
+			responseStatus: 200,
+			expected:       []Domain{},
+			expectedError:  false,
+			login:          "testuser",
+			password:       "testpass",
+			pageNo:         3,
+		},
+		{
+			name:           "unauthorized error",
+			responseBody:   `{"error":"unauthorized"}`,
+			responseStatus: 401,
+			expectedError:  true,
+			login:          "wronguser",
+			password:       "wrongpass",
+			pageNo:         1,
+		},
+		{
+			name:           "server error",
+			responseBody:   `{"error":"internal server error"}`,
+			responseStatus: 500,
+			expectedError:  true,
+			login:          "testuser",
+			password:       "testpass",
+			pageNo:         1,
+		},
+		{
+			name:           "invalid JSON",
+			responseBody:   `[{"name":`,
+			responseStatus: 200,
+			expectedError:  true,
+			login:          "testuser",
+			password:       "testpass",
+			pageNo:         1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Verify the request
+				assert.Equal(t, "GET", r.Method)
+				assert.Equal(t, "/v1/domains", r.URL.Path)
+				assert.Equal(t, "application/json", r.Header.Get("Accept"))
+				assert.Equal(t, DefaultUserAgent, r.Header.Get("User-Agent"))
+
+				// Check basic auth
+				username, password, ok := r.BasicAuth()
+				assert.True(t, ok, "Basic auth should be present")
+				assert.Equal(t, tt.login, username)
+				assert.Equal(t, tt.password, password)
+
+				// Check query parameters
+				assert.Equal(t, "100", r.URL.Query().Get("limit"))
+				assert.Equal(t, strconv.Itoa(tt.pageNo), r.URL.Query().Get("page"))
+
+				w.WriteHeader(tt.responseStatus)
+				w.Write([]byte(tt.responseBody))
+			}))
+			defer server.Close()
+
+			client := NewClient(tt.login, tt.password, WithBaseURL(server.URL+"/v1/"))
+			result, err := client.ListDomains(context.Background(), tt.pageNo, DefaultPageSize)
+
+			if tt.expectedError {
+				assert.Error(t, err, "Expected an error for status %d", tt.responseStatus)
+			} else {
+				require.NoError(t, err, "Unexpected error for successful request")
+				assert.Equal(t, tt.expected, result, "Decoded domains should match")
+			}
+		})
+	}
+}
+
+func TestClient_Options(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client := NewClient("testuser", "testpass",
+		WithBaseURL(server.URL),
+		WithHTTPClient(server.Client()),
+		WithUserAgent("custom-tool/1.0"),
+	)
+	_, err := client.ListDomains(context.Background(), 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "custom-tool/1.0", userAgent)
+}
+
+func TestClient_ListDomains_ContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := NewClient("testuser", "testpass", WithBaseURL(server.URL))
+	_, err := client.ListDomains(ctx, 1, 10)
+	assert.ErrorIs(t, err, context.Canceled)
+}
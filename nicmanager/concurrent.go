@@ -0,0 +1,94 @@
+package nicmanager
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// WithConcurrency lets Domains fetch up to workers pages at the same time,
+// the domains are still yielded in page order. The rate limit applies to
+// all workers together.
+func WithConcurrency(workers int) Option {
+	return func(c *Client) {
+		c.concurrency = max(workers, 1)
+	}
+}
+
+// pageResult is the outcome of fetching a single page
+type pageResult struct {
+	page *DomainPage
+	err  error
+}
+
+// domainsConcurrent is the parallel implementation of Domains
+//
+// Pages are requested speculatively ahead of the consumer. The queue of
+// pending pages is bounded so that no more than workers requests are in
+// flight, and each page has its own result channel so results are consumed
+// in page order no matter when they arrive. As soon as the last page is
+// known, either from a page without successor or from the total count,
+// no further pages are requested and pages past the end are discarded.
+func (c *Client) domainsConcurrent(ctx context.Context, pageSize int, yield func(Domain, error) bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	// lastPage is 0 as long as the end of the listing is unknown
+	var lastPage atomic.Int64
+	pending := make(chan chan pageResult, c.concurrency-1)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(pending)
+
+		for pageNo := 1; ; pageNo++ {
+			if last := lastPage.Load(); last > 0 && int64(pageNo) > last {
+				return
+			}
+
+			result := make(chan pageResult, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				page, err := c.ListDomainsPage(ctx, pageNo, pageSize)
+				result <- pageResult{page: page, err: err}
+			}()
+		}
+	}()
+
+	for result := range pending {
+		res := <-result
+		if res.err != nil {
+			yield(Domain{}, res.err)
+			return
+		}
+
+		if res.page.Total >= 0 {
+			lastPage.CompareAndSwap(0, int64(max((res.page.Total+pageSize-1)/pageSize, 1)))
+		}
+
+		for _, domain := range res.page.Domains {
+			if !yield(domain, nil) {
+				return
+			}
+		}
+
+		if !res.page.HasNext {
+			return
+		}
+	}
+
+	// the queue only ends early if the caller canceled the context
+	if err := ctx.Err(); err != nil {
+		yield(Domain{}, err)
+	}
+}
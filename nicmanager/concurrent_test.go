@@ -0,0 +1,200 @@
+package nicmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowPaginatedServer serves total domains where early pages take longer
+// than later ones, so responses arrive out of order
+type slowPaginatedServer struct {
+	*httptest.Server
+	requests    atomic.Int64
+	inFlight    atomic.Int64
+	maxInFlight atomic.Int64
+
+	mu        sync.Mutex
+	pagesSeen []int
+}
+
+func newSlowPaginatedServer(t *testing.T, total int, withTotalCount bool, failPage int) *slowPaginatedServer {
+	s := &slowPaginatedServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		current := s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		for {
+			seen := s.maxInFlight.Load()
+			if current <= seen || s.maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		s.mu.Lock()
+		s.pagesSeen = append(s.pagesSeen, page)
+		s.mu.Unlock()
+
+		time.Sleep(time.Duration(10-page%10) * 2 * time.Millisecond)
+
+		if page == failPage {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		domains := []Domain{}
+		for i := (page - 1) * limit; i < page*limit && i < total; i++ {
+			domains = append(domains, Domain{Name: fmt.Sprintf("domain%03d.com", i)})
+		}
+		if withTotalCount {
+			w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		}
+		json.NewEncoder(w).Encode(domains)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestClient_DomainsConcurrent(t *testing.T) {
+	tests := []struct {
+		name           string
+		total          int
+		workers        int
+		withTotalCount bool
+		maxRequests    int64
+	}{
+		{
+			name:        "short last page",
+			total:       95,
+			workers:     4,
+			maxRequests: 10 + 4,
+		},
+		{
+			name:        "empty page after exact multiple",
+			total:       100,
+			workers:     3,
+			maxRequests: 11 + 3,
+		},
+		{
+			name:           "total count stops at the last page",
+			total:          100,
+			workers:        4,
+			withTotalCount: true,
+			maxRequests:    10 + 4,
+		},
+		{
+			name:        "single page",
+			total:       3,
+			workers:     8,
+			maxRequests: 1 + 8,
+		},
+		{
+			name:        "more workers than pages",
+			total:       0,
+			workers:     5,
+			maxRequests: 1 + 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSlowPaginatedServer(t, tt.total, tt.withTotalCount, -1)
+			client := NewClient("testuser", "testpass", WithBaseURL(server.URL), WithConcurrency(tt.workers))
+
+			names := collectNames(t, client, 10)
+
+			require.Len(t, names, tt.total)
+			for i, name := range names {
+				assert.Equal(t, fmt.Sprintf("domain%03d.com", i), name, "Domains should be yielded in page order")
+			}
+			assert.LessOrEqual(t, server.maxInFlight.Load(), int64(tt.workers), "Concurrency limit exceeded")
+			assert.LessOrEqual(t, server.requests.Load(), tt.maxRequests, "Too many pages requested past the end")
+		})
+	}
+}
+
+func TestClient_DomainsConcurrent_TotalCountAvoidsSpeculation(t *testing.T) {
+	server := newSlowPaginatedServer(t, 100, true, -1)
+	client := NewClient("testuser", "testpass", WithBaseURL(server.URL), WithConcurrency(2))
+
+	names := collectNames(t, client, 10)
+
+	assert.Len(t, names, 100)
+	// with two workers at most one page past the first can be in flight when the total becomes known
+	assert.LessOrEqual(t, server.requests.Load(), int64(10))
+	for _, page := range server.pagesSeen {
+		assert.LessOrEqual(t, page, 10, "No page beyond the total should be requested")
+	}
+}
+
+func TestClient_DomainsConcurrent_Error(t *testing.T) {
+	server := newSlowPaginatedServer(t, 100, false, 4)
+	client := NewClient("testuser", "testpass", WithBaseURL(server.URL), WithConcurrency(4))
+
+	var names []string
+	var errs []error
+	for domain, err := range client.Domains(context.Background(), 10) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		names = append(names, domain.Name)
+	}
+
+	require.Len(t, errs, 1, "The iteration should stop after the first error")
+	assert.Contains(t, errs[0].Error(), "401")
+	assert.Len(t, names, 30, "All pages before the failing one should be yielded")
+}
+
+func TestClient_DomainsConcurrent_StopEarly(t *testing.T) {
+	server := newSlowPaginatedServer(t, 1000, false, -1)
+	client := NewClient("testuser", "testpass", WithBaseURL(server.URL), WithConcurrency(4))
+
+	count := 0
+	for _, err := range client.Domains(context.Background(), 10) {
+		require.NoError(t, err)
+		count++
+		if count == 25 {
+			break
+		}
+	}
+
+	assert.Equal(t, 25, count)
+	assert.LessOrEqual(t, server.requests.Load(), int64(3+4), "Fetching should stop after break")
+}
+
+func TestClient_DomainsConcurrent_Canceled(t *testing.T) {
+	server := newSlowPaginatedServer(t, 1000, false, -1)
+	client := NewClient("testuser", "testpass", WithBaseURL(server.URL), WithConcurrency(4))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	count := 0
+	var lastErr error
+	for _, err := range client.Domains(ctx, 10) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		count++
+		if count == 15 {
+			cancel()
+		}
+	}
+
+	assert.ErrorIs(t, lastErr, context.Canceled, "Cancellation must be reported, not look like the end of the list")
+	assert.Less(t, count, 1000)
+}
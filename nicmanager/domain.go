@@ -0,0 +1,120 @@
+package nicmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Domain represents a domain entry from the API
+type Domain struct {
+	Name                 string `json:"name"`
+	OrderStatus          string `json:"order_status"`
+	OrderDateTime        string `json:"order_datetime"`
+	RegistrationDateTime string `json:"registration_datetime"`
+	DeleteDateTime       string `json:"delete_datetime"`
+	// Extra holds all fields of the API response not mapped above, with their
+	// raw JSON values, so new API fields are not lost. Nil if there are none.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// domainFields are the JSON names of the mapped fields of Domain
+var domainFields = []string{"name", "order_status", "order_datetime", "registration_datetime", "delete_datetime"}
+
+// plainDomain is Domain without its JSON methods
+type plainDomain Domain
+
+// UnmarshalJSON decodes the mapped fields and keeps every other field in
+// Extra as compact JSON
+func (d *Domain) UnmarshalJSON(data []byte) error {
+	var plain plainDomain
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, name := range domainFields {
+		delete(fields, name)
+	}
+	// compact values, so the indentation of the response does not end up
+	// in output columns
+	for name, value := range fields {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, value); err != nil {
+			return err
+		}
+		fields[name] = compact.Bytes()
+	}
+	if len(fields) > 0 {
+		plain.Extra = fields
+	}
+
+	*d = Domain(plain)
+	return nil
+}
+
+// MarshalJSON encodes the mapped fields followed by the Extra fields in
+// alphabetical order, so a decoded domain encodes to the same fields again
+func (d Domain) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(plainDomain(d))
+	if err != nil || len(d.Extra) == 0 {
+		return data, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for _, name := range slices.Sorted(maps.Keys(d.Extra)) {
+		if slices.Contains(domainFields, name) {
+			continue
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value := d.Extra[name]
+		if len(value) == 0 {
+			value = json.RawMessage("null")
+		}
+		if !json.Valid(value) {
+			return nil, fmt.Errorf("extra field %s: invalid JSON value", name)
+		}
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// timeLayouts are the RFC 3339 variants accepted by ParseTime. Fractional
+// seconds are accepted by time.Parse for all of them.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02t15:04:05Z07:00",
+}
+
+// ParseTime parses timestamps as returned by the Nicmanager API. Any RFC 3339
+// timestamp is accepted, with or without fractional seconds and with either
+// Z or a numeric offset; the result is in UTC. Timestamps without a zone are
+// rejected since their meaning is ambiguous.
+func ParseTime(value string) (time.Time, error) {
+	var firstErr error
+	for _, layout := range timeLayouts {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			return parsed.UTC(), nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, fmt.Errorf("not an RFC 3339 timestamp: %w", firstErr)
+}
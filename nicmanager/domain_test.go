@@ -0,0 +1,109 @@
+package nicmanager
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const domainWithExtras = `{
+	"name": "example.com",
+	"order_status": "active",
+	"order_datetime": "2023-01-01T00:00:00Z",
+	"registration_datetime": "2023-01-02T00:00:00Z",
+	"delete_datetime": "",
+	"auth_code_status": "locked",
+	"nameservers": ["ns1.example.net", "ns2.example.net"],
+	"renewal_date": null,
+	"owner": {"handle": "OWN-1", "country": "DE"}
+}`
+
+func TestDomain_UnmarshalJSON_Extra(t *testing.T) {
+	var domain Domain
+	require.NoError(t, json.Unmarshal([]byte(domainWithExtras), &domain))
+
+	assert.Equal(t, "example.com", domain.Name)
+	assert.Equal(t, "active", domain.OrderStatus)
+	assert.Equal(t, "2023-01-02T00:00:00Z", domain.RegistrationDateTime)
+
+	require.Len(t, domain.Extra, 4, "Only the unmapped fields should be in Extra")
+	assert.JSONEq(t, `"locked"`, string(domain.Extra["auth_code_status"]))
+	assert.JSONEq(t, `["ns1.example.net", "ns2.example.net"]`, string(domain.Extra["nameservers"]))
+	assert.JSONEq(t, `null`, string(domain.Extra["renewal_date"]))
+	assert.Equal(t, `{"handle":"OWN-1","country":"DE"}`, string(domain.Extra["owner"]), "Values should be compacted")
+}
+
+func TestDomain_UnmarshalJSON_NoExtra(t *testing.T) {
+	var domain Domain
+	require.NoError(t, json.Unmarshal([]byte(`{"name":"example.com","order_status":"active"}`), &domain))
+
+	assert.Equal(t, Domain{Name: "example.com", OrderStatus: "active"}, domain)
+	assert.Nil(t, domain.Extra)
+}
+
+func TestDomain_UnmarshalJSON_Invalid(t *testing.T) {
+	var domain Domain
+	assert.Error(t, json.Unmarshal([]byte(`{"name": 42}`), &domain))
+	assert.Error(t, json.Unmarshal([]byte(`["example.com"]`), &domain))
+}
+
+func TestDomain_MarshalJSON(t *testing.T) {
+	var domain Domain
+	require.NoError(t, json.Unmarshal([]byte(domainWithExtras), &domain))
+
+	data, err := json.Marshal(domain)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"example.com","order_status":"active","order_datetime":"2023-01-01T00:00:00Z",`+
+		`"registration_datetime":"2023-01-02T00:00:00Z","delete_datetime":"",`+
+		`"auth_code_status":"locked","nameservers":["ns1.example.net","ns2.example.net"],`+
+		`"owner":{"handle":"OWN-1","country":"DE"},"renewal_date":null}`, string(data),
+		"Mapped fields should come first, extra fields sorted by name")
+
+	var again Domain
+	require.NoError(t, json.Unmarshal(data, &again))
+	assert.JSONEq(t, domainWithExtras, string(data), "Encoding should keep every field of the API response")
+}
+
+func TestDomain_MarshalJSON_Extra(t *testing.T) {
+	tests := []struct {
+		name        string
+		domain      Domain
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "no extra fields",
+			domain:   Domain{Name: "example.com"},
+			expected: `{"name":"example.com","order_status":"","order_datetime":"","registration_datetime":"","delete_datetime":""}`,
+		},
+		{
+			name:     "extra fields do not override mapped ones",
+			domain:   Domain{Name: "example.com", Extra: map[string]json.RawMessage{"name": json.RawMessage(`"other.com"`)}},
+			expected: `{"name":"example.com","order_status":"","order_datetime":"","registration_datetime":"","delete_datetime":""}`,
+		},
+		{
+			name:     "empty raw value is null",
+			domain:   Domain{Name: "example.com", Extra: map[string]json.RawMessage{"handle": nil}},
+			expected: `{"name":"example.com","order_status":"","order_datetime":"","registration_datetime":"","delete_datetime":"","handle":null}`,
+		},
+		{
+			name:        "invalid raw value",
+			domain:      Domain{Name: "example.com", Extra: map[string]json.RawMessage{"handle": json.RawMessage(`{`)}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.domain)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(data))
+		})
+	}
+}
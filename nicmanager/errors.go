@@ -0,0 +1,94 @@
+package nicmanager
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxErrorBody limits how much of a response body ends up in an error message
+const maxErrorBody = 200
+
+// APIStatusError is returned when the API answers with an unexpected status
+// code. AuthError and RateLimitError wrap it for the status codes callers
+// usually want to handle separately.
+type APIStatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *APIStatusError) Error() string {
+	msg := fmt.Sprintf("nicmanager: %s %s: status code error: %s", e.Method, e.Path, e.Status)
+	if len(e.Body) > 0 {
+		body := string(e.Body)
+		if len(body) > maxErrorBody {
+			body = body[:maxErrorBody] + "..."
+		}
+		msg += ": " + body
+	}
+	return msg
+}
+
+// AuthError is returned for 401 and 403 responses, i.e. wrong credentials or
+// an API user without access to the requested data
+type AuthError struct {
+	APIStatusError
+}
+
+func (e *AuthError) Unwrap() error {
+	return &e.APIStatusError
+}
+
+// RateLimitError is returned when the API still answers 429 after all retries
+type RateLimitError struct {
+	APIStatusError
+	// RetryAfter is the wait the API asked for, zero if it did not say
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return &e.APIStatusError
+}
+
+// DecodeError is returned when a response body is not the expected JSON
+type DecodeError struct {
+	Path string
+	// Page is the page of a paginated listing, zero otherwise
+	Page int
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Page > 0 {
+		return fmt.Sprintf("nicmanager: decoding page %d of %s: %v", e.Page, e.Path, e.Err)
+	}
+	return fmt.Sprintf("nicmanager: decoding response of %s: %v", e.Path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// newStatusError builds the error matching the status code of res
+func newStatusError(res *http.Response, body []byte) error {
+	statusErr := APIStatusError{
+		Method:     res.Request.Method,
+		Path:       res.Request.URL.Path,
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Body:       body,
+	}
+
+	switch res.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &AuthError{APIStatusError: statusErr}
+	case http.StatusTooManyRequests:
+		retryAfter, _ := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+		return &RateLimitError{APIStatusError: statusErr, RetryAfter: retryAfter}
+	default:
+		return &statusErr
+	}
+}
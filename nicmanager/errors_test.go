@@ -0,0 +1,129 @@
+package nicmanager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_TypedErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		header     map[string]string
+		body       string
+		assertType func(t *testing.T, err error)
+	}{
+		{
+			name:   "401 is an AuthError",
+			status: http.StatusUnauthorized,
+			body:   `{"error":"unauthorized"}`,
+			assertType: func(t *testing.T, err error) {
+				var authErr *AuthError
+				require.ErrorAs(t, err, &authErr)
+				assert.Equal(t, http.StatusUnauthorized, authErr.StatusCode)
+			},
+		},
+		{
+			name:   "403 is an AuthError",
+			status: http.StatusForbidden,
+			assertType: func(t *testing.T, err error) {
+				var authErr *AuthError
+				require.ErrorAs(t, err, &authErr)
+			},
+		},
+		{
+			name:   "429 after all retries is a RateLimitError",
+			status: http.StatusTooManyRequests,
+			header: map[string]string{"Retry-After": "42"},
+			assertType: func(t *testing.T, err error) {
+				var rateErr *RateLimitError
+				require.ErrorAs(t, err, &rateErr)
+				assert.Equal(t, 42*time.Second, rateErr.RetryAfter)
+			},
+		},
+		{
+			name:   "500 is a plain APIStatusError",
+			status: http.StatusInternalServerError,
+			body:   `{"error":"internal server error"}`,
+			assertType: func(t *testing.T, err error) {
+				var authErr *AuthError
+				var rateErr *RateLimitError
+				assert.False(t, errors.As(err, &authErr))
+				assert.False(t, errors.As(err, &rateErr))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, value := range tt.header {
+					w.Header().Set(key, value)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client, _ := newRecordingClient(server.URL, DefaultRetryPolicy)
+			_, err := client.ListDomains(context.Background(), 1, 10)
+			require.Error(t, err)
+
+			// every status error is an APIStatusError, including the specialized ones
+			var statusErr *APIStatusError
+			require.ErrorAs(t, err, &statusErr)
+			assert.Equal(t, tt.status, statusErr.StatusCode)
+			assert.Equal(t, "GET", statusErr.Method)
+			assert.Equal(t, "/domains", statusErr.Path)
+			assert.Equal(t, tt.body, string(statusErr.Body))
+			tt.assertType(t, err)
+		})
+	}
+}
+
+func TestClient_DecodeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.Write([]byte(`<html>maintenance</html>`))
+			return
+		}
+		w.Write([]byte(`[{"name":"a.com"},{"name":"b.com"}]`))
+	}))
+	defer server.Close()
+
+	client := NewClient("testuser", "testpass", WithBaseURL(server.URL))
+
+	var lastErr error
+	for _, err := range client.Domains(context.Background(), 2) {
+		lastErr = err
+	}
+
+	var decodeErr *DecodeError
+	require.ErrorAs(t, lastErr, &decodeErr)
+	assert.Equal(t, 2, decodeErr.Page)
+	assert.Equal(t, "/domains", decodeErr.Path)
+	assert.Contains(t, decodeErr.Error(), "decoding page 2 of /domains")
+}
+
+func TestAPIStatusError_Error(t *testing.T) {
+	err := &APIStatusError{
+		Method:     "GET",
+		Path:       "/domains",
+		StatusCode: 502,
+		Status:     "502 Bad Gateway",
+		Body:       []byte(strings.Repeat("x", 500)),
+	}
+
+	msg := err.Error()
+	assert.True(t, strings.HasPrefix(msg, "nicmanager: GET /domains: status code error: 502 Bad Gateway: xxx"))
+	assert.True(t, strings.HasSuffix(msg, "..."), "Long bodies should be truncated")
+	assert.Less(t, len(msg), 300)
+}
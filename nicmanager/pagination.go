@@ -0,0 +1,124 @@
+package nicmanager
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DomainPage is one page of the domain listing
+type DomainPage struct {
+	Domains []Domain
+	// Page is the number of this page, starting at 1
+	Page int
+	// Total is the number of domains in the whole listing or -1 if the API
+	// did not report it
+	Total int
+	// HasNext tells whether another page follows this one
+	HasNext bool
+}
+
+// ListDomainsPage fetches one page of the domain list including the
+// pagination metadata, pages start at 1
+//
+// Whether more pages follow is taken from a Link header with rel="next" if
+// the API sends one, from the X-Total-Count header otherwise, and if neither
+// is present a full page is taken as a sign that more may follow.
+func (c *Client) ListDomainsPage(ctx context.Context, page int, limit int) (*DomainPage, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("page", strconv.Itoa(page))
+
+	var domains []Domain
+	header, err := c.get(ctx, "/domains", query, &domains)
+	if err != nil {
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			decodeErr.Page = page
+		}
+		return nil, err
+	}
+
+	domainPage := &DomainPage{
+		Domains: domains,
+		Page:    page,
+		Total:   totalCount(header),
+	}
+
+	switch link := header.Get("Link"); {
+	case link != "":
+		domainPage.HasNext = hasNextLink(link)
+	case domainPage.Total >= 0:
+		domainPage.HasNext = page*limit < domainPage.Total
+	default:
+		domainPage.HasNext = len(domains) == limit
+	}
+	// an empty page never announces another one, whatever the headers say
+	if len(domains) == 0 {
+		domainPage.HasNext = false
+	}
+
+	return domainPage, nil
+}
+
+// Domains iterates over the whole domain list, fetching pages of pageSize
+// entries as needed. On the first error the error is yielded and the
+// iteration stops.
+func (c *Client) Domains(ctx context.Context, pageSize int) iter.Seq2[Domain, error] {
+	return func(yield func(Domain, error) bool) {
+		if c.concurrency > 1 {
+			c.domainsConcurrent(ctx, pageSize, yield)
+			return
+		}
+
+		for pageNo := 1; ; pageNo++ {
+			domainPage, err := c.ListDomainsPage(ctx, pageNo, pageSize)
+			if err != nil {
+				yield(Domain{}, err)
+				return
+			}
+
+			for _, domain := range domainPage.Domains {
+				if !yield(domain, nil) {
+					return
+				}
+			}
+
+			if !domainPage.HasNext {
+				return
+			}
+		}
+	}
+}
+
+// totalCount reads the X-Total-Count header, -1 means unknown
+func totalCount(header http.Header) int {
+	total, err := strconv.Atoi(strings.TrimSpace(header.Get("X-Total-Count")))
+	if err != nil || total < 0 {
+		return -1
+	}
+	return total
+}
+
+// hasNextLink reports whether an RFC 8288 Link header contains a rel="next" link
+func hasNextLink(link string) bool {
+	for _, linkValue := range strings.Split(link, ",") {
+		params := strings.Split(linkValue, ";")
+		for _, param := range params[1:] {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || !strings.EqualFold(key, "rel") {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+				if strings.EqualFold(rel, "next") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package nicmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPaginatedServer serves total domains in pages of the requested limit,
+// headers adds pagination headers to every response
+func newPaginatedServer(t *testing.T, total int, headers func(w http.ResponseWriter, page int, limit int)) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		domains := []Domain{}
+		for i := (page - 1) * limit; i < page*limit && i < total; i++ {
+			domains = append(domains, Domain{Name: fmt.Sprintf("domain%03d.com", i)})
+		}
+
+		if headers != nil {
+			headers(w, page, limit)
+		}
+		json.NewEncoder(w).Encode(domains)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func collectNames(t *testing.T, client *Client, pageSize int) []string {
+	var names []string
+	for domain, err := range client.Domains(context.Background(), pageSize) {
+		require.NoError(t, err)
+		names = append(names, domain.Name)
+	}
+	return names
+}
+
+func TestClient_Domains(t *testing.T) {
+	tests := []struct {
+		name             string
+		total            int
+		pageSize         int
+		headers          func(w http.ResponseWriter, page int, limit int)
+		expectedRequests int
+	}{
+		{
+			name:             "short last page without headers",
+			total:            25,
+			pageSize:         10,
+			expectedRequests: 3,
+		},
+		{
+			name:             "exact multiple without headers needs an extra empty page",
+			total:            20,
+			pageSize:         10,
+			expectedRequests: 3,
+		},
+		{
+			name:     "exact multiple with total count",
+			total:    20,
+			pageSize: 10,
+			headers: func(w http.ResponseWriter, page int, limit int) {
+				w.Header().Set("X-Total-Count", "20")
+			},
+			expectedRequests: 2,
+		},
+		{
+			name:     "link header with next relation",
+			total:    20,
+			pageSize: 10,
+			headers: func(w http.ResponseWriter, page int, limit int) {
+				link := fmt.Sprintf(`</v1/domains?page=1&limit=%d>; rel="first"`, limit)
+				if page < 2 {
+					link += fmt.Sprintf(`, </v1/domains?page=%d&limit=%d>; rel="next"`, page+1, limit)
+				}
+				w.Header().Set("Link", link)
+			},
+			expectedRequests: 2,
+		},
+		{
+			name:     "link header wins over total count",
+			total:    30,
+			pageSize: 10,
+			headers: func(w http.ResponseWriter, page int, limit int) {
+				w.Header().Set("X-Total-Count", "10")
+				if page < 3 {
+					w.Header().Set("Link", fmt.Sprintf(`</v1/domains?page=%d>; rel="next last"`, page+1))
+				} else {
+					w.Header().Set("Link", `</v1/domains?page=1>; rel="first"`)
+				}
+			},
+			expectedRequests: 3,
+		},
+		{
+			name:             "empty listing",
+			total:            0,
+			pageSize:         10,
+			expectedRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newPaginatedServer(t, tt.total, tt.headers)
+			client := NewClient("testuser", "testpass", WithBaseURL(server.URL))
+
+			names := collectNames(t, client, tt.pageSize)
+
+			assert.Len(t, names, tt.total)
+			for i, name := range names {
+				assert.Equal(t, fmt.Sprintf("domain%03d.com", i), name, "Domains should be yielded in order")
+			}
+			assert.Equal(t, tt.expectedRequests, *requests, "Unexpected number of page requests")
+		})
+	}
+}
+
+func TestClient_Domains_StopEarly(t *testing.T) {
+	server, requests := newPaginatedServer(t, 100, nil)
+	client := NewClient("testuser", "testpass", WithBaseURL(server.URL))
+
+	count := 0
+	for _, err := range client.Domains(context.Background(), 10) {
+		require.NoError(t, err)
+		count++
+		if count == 15 {
+			break
+		}
+	}
+
+	assert.Equal(t, 15, count)
+	assert.Equal(t, 2, *requests, "No further pages should be fetched after break")
+}
+
+func TestClient_Domains_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`[{"name":"a.com"},{"name":"b.com"}]`))
+	}))
+	defer server.Close()
+
+	client := NewClient("testuser", "testpass", WithBaseURL(server.URL))
+
+	var names []string
+	var errs []error
+	for domain, err := range client.Domains(context.Background(), 2) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		names = append(names, domain.Name)
+	}
+
+	assert.Equal(t, []string{"a.com", "b.com"}, names)
+	require.Len(t, errs, 1, "The iteration should stop after the first error")
+	assert.Contains(t, errs[0].Error(), "500")
+}
+
+func TestHasNextLink(t *testing.T) {
+	tests := []struct {
+		link     string
+		expected bool
+	}{
+		{`<https://api.nicmanager.com/v1/domains?page=2>; rel="next"`, true},
+		{`<https://api.nicmanager.com/v1/domains?page=2>; rel=next`, true},
+		{`<https://api.nicmanager.com/v1/domains?page=1>; rel="prev", <https://api.nicmanager.com/v1/domains?page=3>; rel="next"`, true},
+		{`<https://api.nicmanager.com/v1/domains?page=3>; REL="Next Last"`, true},
+		{`<https://api.nicmanager.com/v1/domains?page=1>; rel="first", <https://api.nicmanager.com/v1/domains?page=1>; rel="prev"`, false},
+		{`<https://api.nicmanager.com/v1/domains?page=2>`, false},
+		{``, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, hasNextLink(tt.link), "Link: %s", tt.link)
+	}
+}
@@ -0,0 +1,36 @@
+package nicmanager
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// WithRateLimit limits the client to requestsPerSecond on average with
+// bursts of up to burst requests, retries count as requests as well.
+// A rate of zero or below removes the limit.
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(c *Client) {
+		if requestsPerSecond <= 0 {
+			c.limiter = nil
+			return
+		}
+		c.limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), max(burst, 1))
+	}
+}
+
+// waitForRateLimit blocks until the token bucket allows the next request
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	if c.limiter == nil {
+		return nil
+	}
+
+	start := time.Now()
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	c.logf("rate limit %.2f req/s (burst %d): waited %s, %.2f tokens left",
+		float64(c.limiter.Limit()), c.limiter.Burst(), time.Since(start).Round(time.Millisecond), c.limiter.Tokens())
+	return nil
+}
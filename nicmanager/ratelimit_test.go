@@ -0,0 +1,75 @@
+package nicmanager
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_RateLimit(t *testing.T) {
+	server, requests := newPaginatedServer(t, 50, nil)
+
+	var logBuffer bytes.Buffer
+	client := NewClient("testuser", "testpass",
+		WithBaseURL(server.URL),
+		WithRateLimit(50, 1),
+		WithLogger(log.New(&logBuffer, "", 0)),
+	)
+
+	start := time.Now()
+	names := collectNames(t, client, 10)
+	elapsed := time.Since(start)
+
+	assert.Len(t, names, 50)
+	assert.Equal(t, 6, *requests)
+	// the first request uses the burst, the other five wait 20ms each
+	assert.GreaterOrEqual(t, elapsed, 90*time.Millisecond, "Requests should be spaced by the limiter")
+	assert.Contains(t, logBuffer.String(), "rate limit 50.00 req/s (burst 1)")
+}
+
+func TestClient_RateLimitAppliesToRetries(t *testing.T) {
+	server, requests := newFlakyServer(t, 2, failWithStatus(http.StatusServiceUnavailable))
+
+	client := NewClient("testuser", "testpass",
+		WithBaseURL(server.URL),
+		WithRateLimit(20, 1),
+	)
+	client.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	start := time.Now()
+	_, err := client.ListDomains(context.Background(), 1, 10)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, *requests)
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "Retries should wait for the limiter as well")
+}
+
+func TestClient_RateLimitCanceled(t *testing.T) {
+	server, requests := newPaginatedServer(t, 1, nil)
+
+	client := NewClient("testuser", "testpass",
+		WithBaseURL(server.URL),
+		WithRateLimit(0.01, 1),
+	)
+
+	// the first request uses the burst
+	_, err := client.ListDomains(context.Background(), 1, 10)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.ListDomains(ctx, 1, 10)
+	assert.Error(t, err, "Waiting for the limiter should respect the context")
+	assert.Equal(t, 1, *requests)
+}
+
+func TestWithRateLimit_Disabled(t *testing.T) {
+	client := NewClient("testuser", "testpass", WithRateLimit(10, 1), WithRateLimit(0, 0))
+	assert.Nil(t, client.limiter)
+}
@@ -0,0 +1,104 @@
+package nicmanager
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how requests failing with a transient error are retried
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one,
+	// values below 2 disable retries
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, it doubles with every
+	// further attempt and is randomized by up to half to spread retries
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between two attempts, a longer Retry-After
+	// is cut down to it as well
+	MaxDelay time.Duration
+	// RetryStatus lists the HTTP status codes that are considered transient
+	RetryStatus []int
+}
+
+// DefaultRetryPolicy is used by clients created without WithRetryPolicy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	RetryStatus: []int{
+		http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+// NoRetry sends every request exactly once
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// WithRetryPolicy replaces DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// retryStatus reports whether a response with the status code is retried
+func (p RetryPolicy) retryStatus(statusCode int) bool {
+	return slices.Contains(p.RetryStatus, statusCode)
+}
+
+// backoff returns the wait before the given retry (1 for the first retry),
+// a Retry-After header of the failed response takes precedence
+func (p RetryPolicy) backoff(retry int, header http.Header, now time.Time) time.Duration {
+	delay, ok := parseRetryAfter(header.Get("Retry-After"), now)
+	if !ok {
+		delay = p.BaseDelay << (retry - 1)
+		if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+			delay = p.MaxDelay
+		}
+		if delay > 1 {
+			delay = delay/2 + rand.N(delay/2)
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// parseRetryAfter understands both forms of Retry-After, delay-seconds and HTTP-date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,227 @@
+package nicmanager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFlakyServer answers the first failures requests with the handler fail
+// and all further ones with an empty domain list
+func newFlakyServer(t *testing.T, failures int, fail http.HandlerFunc) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			fail(w, r)
+			return
+		}
+		w.Write([]byte(`[{"name":"example.com"}]`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// newRecordingClient creates a client that records the waits between retries instead of sleeping
+func newRecordingClient(baseURL string, policy RetryPolicy) (*Client, *[]time.Duration) {
+	var delays []time.Duration
+	client := NewClient("testuser", "testpass", WithBaseURL(baseURL), WithRetryPolicy(policy))
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return client, &delays
+}
+
+func failWithStatus(statusCode int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+	}
+}
+
+func TestClient_Retry(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         int
+		fail             http.HandlerFunc
+		expectedError    bool
+		expectedRequests int
+	}{
+		{
+			name:             "recovers from 503",
+			failures:         2,
+			fail:             failWithStatus(http.StatusServiceUnavailable),
+			expectedRequests: 3,
+		},
+		{
+			name:             "recovers from 429",
+			failures:         1,
+			fail:             failWithStatus(http.StatusTooManyRequests),
+			expectedRequests: 2,
+		},
+		{
+			name:             "recovers from 502",
+			failures:         4,
+			fail:             failWithStatus(http.StatusBadGateway),
+			expectedRequests: 5,
+		},
+		{
+			name:             "gives up after max attempts",
+			failures:         5,
+			fail:             failWithStatus(http.StatusGatewayTimeout),
+			expectedError:    true,
+			expectedRequests: 5,
+		},
+		{
+			name:             "no retry on 401",
+			failures:         1,
+			fail:             failWithStatus(http.StatusUnauthorized),
+			expectedError:    true,
+			expectedRequests: 1,
+		},
+		{
+			name:             "no retry on 500",
+			failures:         1,
+			fail:             failWithStatus(http.StatusInternalServerError),
+			expectedError:    true,
+			expectedRequests: 1,
+		},
+		{
+			name:     "recovers from dropped connection",
+			failures: 2,
+			fail: func(w http.ResponseWriter, r *http.Request) {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				require.NoError(t, err)
+				conn.Close()
+			},
+			expectedRequests: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newFlakyServer(t, tt.failures, tt.fail)
+			client, delays := newRecordingClient(server.URL, DefaultRetryPolicy)
+
+			domains, err := client.ListDomains(context.Background(), 1, 10)
+
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Len(t, domains, 1)
+			}
+			assert.Equal(t, tt.expectedRequests, *requests, "Unexpected number of requests")
+			assert.Len(t, *delays, tt.expectedRequests-1, "Every retry should wait")
+		})
+	}
+}
+
+func TestClient_RetryAfter(t *testing.T) {
+	t.Run("delay seconds", func(t *testing.T) {
+		server, _ := newFlakyServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+		client, delays := newRecordingClient(server.URL, DefaultRetryPolicy)
+
+		_, err := client.ListDomains(context.Background(), 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{7 * time.Second}, *delays)
+	})
+
+	t.Run("capped by max delay", func(t *testing.T) {
+		server, _ := newFlakyServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		client, delays := newRecordingClient(server.URL, DefaultRetryPolicy)
+
+		_, err := client.ListDomains(context.Background(), 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{DefaultRetryPolicy.MaxDelay}, *delays)
+	})
+}
+
+func TestClient_NoRetry(t *testing.T) {
+	server, requests := newFlakyServer(t, 1, failWithStatus(http.StatusServiceUnavailable))
+	client, delays := newRecordingClient(server.URL, NoRetry)
+
+	_, err := client.ListDomains(context.Background(), 1, 10)
+	assert.Error(t, err)
+	assert.Equal(t, 1, *requests)
+	assert.Empty(t, *delays)
+}
+
+func TestClient_RetryCanceled(t *testing.T) {
+	server, requests := newFlakyServer(t, 10, failWithStatus(http.StatusServiceUnavailable))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewClient("testuser", "testpass", WithBaseURL(server.URL))
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return sleepContext(ctx, d)
+	}
+
+	_, err := client.ListDomains(ctx, 1, 10)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, *requests, "No further attempt after cancellation")
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 10,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    time.Second,
+	}
+
+	tests := []struct {
+		retry int
+		min   time.Duration
+		max   time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{4, 400 * time.Millisecond, 800 * time.Millisecond},
+		{5, 500 * time.Millisecond, time.Second},
+		{60, 500 * time.Millisecond, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			delay := policy.backoff(tt.retry, http.Header{}, time.Now())
+			assert.GreaterOrEqual(t, delay, tt.min, "retry %d", tt.retry)
+			assert.LessOrEqual(t, delay, tt.max, "retry %d", tt.retry)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value         string
+		expected      time.Duration
+		expectedFound bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"Sat, 01 Mar 2025 12:00:30 GMT", 30 * time.Second, true},
+		{"Sat, 01 Mar 2025 11:00:00 GMT", 0, true},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		delay, found := parseRetryAfter(tt.value, now)
+		assert.Equal(t, tt.expectedFound, found, "Retry-After: %q", tt.value)
+		assert.Equal(t, tt.expected, delay, "Retry-After: %q", tt.value)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !test && nogui
+
+package main
+
+import (
+	"os"
+)
+
+// main of a build without the Fyne GUI (go build -tags nogui), for headless
+// servers without X11/OpenGL libraries: the default binary links them, so it
+// does not start without them even in the command line mode. Releases ship it
+// as nicmanager-export-nogui.
+func main() {
+	os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+}
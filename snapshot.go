@@ -0,0 +1,431 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/mariow/nicmanager-export/nicmanager"
+)
+
+// the fields of a domain a diff compares, named like their columns
+const (
+	fieldStatus       = "order_status"
+	fieldOrder        = "order_date"
+	fieldRegistration = "registration_date"
+	fieldDelete       = "delete_date"
+)
+
+// diffFields are the compared fields in report order
+var diffFields = []string{fieldStatus, fieldOrder, fieldRegistration, fieldDelete}
+
+// allFields marks every compared field as present
+var allFields = map[string]bool{fieldStatus: true, fieldOrder: true, fieldRegistration: true, fieldDelete: true}
+
+// snapshot is the domain list of one export, read back from a file or
+// fetched from the API
+type snapshot struct {
+	Domains []Domain
+	// Fields marks the diffFields the source contains; a CSV export without
+	// status column, for example, says nothing about the status
+	Fields map[string]bool
+}
+
+// readOptions control how exports are read back
+type readOptions struct {
+	// Location is the time zone of the dates in tabular exports, nil means UTC
+	Location *time.Location
+	// DateLayout is tried first for date cells of CSV exports, before the
+	// layouts of the CSV presets
+	DateLayout string
+	// Columns are the configured columns, their labels are recognized in
+	// the header of tabular exports in addition to the default labels
+	Columns []column
+	// Store resolves references to stored snapshots like snapshot:latest
+	Store *snapshotStore
+}
+
+// readSnapshot reads an export written in any output format, the format is
+// taken from the file extension. path may also be a snapshot file of the
+// store or a reference like snapshot:latest to one.
+func readSnapshot(path string, opts readOptions) (snapshot, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	if id, ok := strings.CutPrefix(path, snapshotRefPrefix); ok {
+		if opts.Store == nil {
+			return snapshot{}, fmt.Errorf("%s: no snapshot store", path)
+		}
+		info, err := opts.Store.lookup(id)
+		if err != nil {
+			return snapshot{}, err
+		}
+		return readStoredSnapshot(info.Path, info.Time, opts.Location)
+	}
+	if id, ok := strings.CutSuffix(filepath.Base(path), snapshotExt); ok {
+		// a snapshot file outside of the store, named after its fetch time
+		// unless renamed
+		fetched, ok := snapshotIDTime(id)
+		if !ok {
+			info, err := os.Stat(path)
+			if err != nil {
+				return snapshot{}, err
+			}
+			fetched = info.ModTime()
+		}
+		return readStoredSnapshot(path, fetched, opts.Location)
+	}
+
+	format, ok := formatFromPath(path)
+	if !ok {
+		return snapshot{}, fmt.Errorf("%s: unknown export format, expected one of the extensions of the output formats", path)
+	}
+
+	var snap snapshot
+	var err error
+	switch format {
+	case formatJSON:
+		snap, err = readJSONSnapshot(path)
+	case formatNDJSON:
+		snap, err = readNDJSONSnapshot(path)
+	case formatSQLite:
+		snap, err = readSQLiteSnapshot(path)
+	case formatXLSX:
+		snap, err = readXLSXSnapshot(path, opts)
+	case formatODS:
+		snap, err = readODSSnapshot(path, opts)
+	default:
+		snap, err = readCSVSnapshot(path, opts)
+	}
+	if err != nil {
+		return snap, fmt.Errorf("%s: %w", path, err)
+	}
+	return snap, nil
+}
+
+func readJSONSnapshot(path string) (snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot{}, err
+	}
+	var domains []Domain
+	if err := json.Unmarshal(data, &domains); err != nil {
+		return snapshot{}, err
+	}
+	return snapshot{Domains: domains, Fields: allFields}, nil
+}
+
+func readNDJSONSnapshot(path string) (snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return snapshot{}, err
+	}
+	defer file.Close()
+	return decodeNDJSON(file)
+}
+
+// decodeNDJSON reads one domain per line, empty lines are skipped
+func decodeNDJSON(in io.Reader) (snapshot, error) {
+	snap := snapshot{Fields: allFields}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var domain Domain
+		if err := json.Unmarshal(scanner.Bytes(), &domain); err != nil {
+			return snap, fmt.Errorf("line %d: %w", line, err)
+		}
+		snap.Domains = append(snap.Domains, domain)
+	}
+	return snap, scanner.Err()
+}
+
+// readSQLiteSnapshot reads the domains of the last finished export run of a
+// database written by the SQLite format
+func readSQLiteSnapshot(path string) (snapshot, error) {
+	if _, err := os.Stat(path); err != nil {
+		return snapshot{}, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return snapshot{}, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT api_record FROM domains
+		WHERE last_seen_run = (SELECT max(id) FROM export_runs WHERE finished_at IS NOT NULL)
+		ORDER BY name`)
+	if err != nil {
+		return snapshot{}, err
+	}
+	defer rows.Close()
+
+	snap := snapshot{Fields: allFields}
+	for rows.Next() {
+		var record string
+		if err := rows.Scan(&record); err != nil {
+			return snap, err
+		}
+		var domain Domain
+		if err := json.Unmarshal([]byte(record), &domain); err != nil {
+			return snap, err
+		}
+		snap.Domains = append(snap.Domains, domain)
+	}
+	return snap, rows.Err()
+}
+
+func readCSVSnapshot(path string, opts readOptions) (snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot{}, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return snapshot{}, err
+	}
+	return tableSnapshot(rows, opts)
+}
+
+// detectDelimiter picks the delimiter of a CSV file from its header line:
+// the one of the usual delimiters it contains most often, comma if none
+func detectDelimiter(data []byte) rune {
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	delimiter, best := ',', 0
+	for _, candidate := range []rune{',', ';', '\t', '|'} {
+		if n := bytes.Count(header, []byte(string(candidate))); n > best {
+			delimiter, best = candidate, n
+		}
+	}
+	return delimiter
+}
+
+func readXLSXSnapshot(path string, opts readOptions) (snapshot, error) {
+	file, err := excelize.OpenFile(path)
+	if err != nil {
+		return snapshot{}, err
+	}
+	defer file.Close()
+
+	// the domains are on the first sheet, whatever it is called
+	rows, err := file.GetRows(file.GetSheetName(0))
+	if err != nil {
+		return snapshot{}, err
+	}
+	return tableSnapshot(rows, opts)
+}
+
+// odsTable is the part of content.xml readODSSnapshot needs
+type odsTable struct {
+	Rows []struct {
+		Cells []struct {
+			Repeated  int    `xml:"urn:oasis:names:tc:opendocument:xmlns:table:1.0 number-columns-repeated,attr"`
+			DateValue string `xml:"urn:oasis:names:tc:opendocument:xmlns:office:1.0 date-value,attr"`
+			Text      string `xml:"urn:oasis:names:tc:opendocument:xmlns:text:1.0 p"`
+		} `xml:"urn:oasis:names:tc:opendocument:xmlns:table:1.0 table-cell"`
+	} `xml:"urn:oasis:names:tc:opendocument:xmlns:table:1.0 table-row"`
+}
+
+// odsMaxRepeated caps repeated cells, spreadsheet programs mark the unused
+// rest of a row as one cell repeated thousands of times
+const odsMaxRepeated = 64
+
+func readODSSnapshot(path string, opts readOptions) (snapshot, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return snapshot{}, err
+	}
+	defer archive.Close()
+
+	content, err := archive.Open("content.xml")
+	if err != nil {
+		return snapshot{}, err
+	}
+	defer content.Close()
+
+	var document struct {
+		Tables []odsTable `xml:"body>spreadsheet>table"`
+	}
+	if err := xml.NewDecoder(content).Decode(&document); err != nil {
+		return snapshot{}, err
+	}
+	if len(document.Tables) == 0 {
+		return snapshot{}, errors.New("no table in the spreadsheet")
+	}
+
+	var rows [][]string
+	for _, row := range document.Tables[0].Rows {
+		var cells []string
+		for _, cell := range row.Cells {
+			value := cell.Text
+			// date cells carry the day as ISO date, whatever they display
+			if cell.DateValue != "" {
+				value, _, _ = strings.Cut(cell.DateValue, "T")
+			}
+			for range min(max(cell.Repeated, 1), odsMaxRepeated) {
+				cells = append(cells, value)
+			}
+		}
+		rows = append(rows, cells)
+	}
+	return tableSnapshot(rows, opts)
+}
+
+// snapshotDateLayouts are tried for date cells after readOptions.DateLayout,
+// the date layouts of all CSV presets
+var snapshotDateLayouts = []string{"2006-01-02", "02.01.2006", "01/02/2006"}
+
+// tableSnapshot turns the rows of a tabular export into a snapshot. The
+// first row is the header, its labels select the columns.
+func tableSnapshot(rows [][]string, opts readOptions) (snapshot, error) {
+	snap := snapshot{Fields: make(map[string]bool)}
+	if len(rows) == 0 {
+		return snap, errors.New("empty export, the header is missing")
+	}
+
+	keys := make([]string, len(rows[0]))
+	nameColumn := -1
+	for i, label := range rows[0] {
+		keys[i] = columnKeyForLabel(label, opts.Columns)
+		switch keys[i] {
+		case "name":
+			nameColumn = i
+		case fieldStatus, fieldOrder, fieldRegistration, fieldDelete:
+			snap.Fields[keys[i]] = true
+		case "order_datetime", "registration_datetime", "delete_datetime":
+			snap.Fields[strings.TrimSuffix(keys[i], "time")] = true
+		}
+	}
+	if nameColumn < 0 {
+		return snap, errors.New("no domain name column in the header")
+	}
+
+	layouts := snapshotDateLayouts
+	if opts.DateLayout != "" {
+		layouts = append([]string{opts.DateLayout}, layouts...)
+	}
+
+	for line, row := range rows[1:] {
+		if nameColumn >= len(row) || strings.TrimSpace(row[nameColumn]) == "" {
+			continue
+		}
+		var domain Domain
+		for i, value := range row {
+			value = strings.TrimSpace(value)
+			if i >= len(keys) || value == "" {
+				continue
+			}
+			var err error
+			switch keys[i] {
+			case "name":
+				domain.Name = value
+			case fieldStatus:
+				domain.OrderStatus = value
+			case "order_datetime":
+				domain.OrderDateTime = value
+			case "registration_datetime":
+				domain.RegistrationDateTime = value
+			case "delete_datetime":
+				domain.DeleteDateTime = value
+			// a raw timestamp column is more precise than the date column
+			case fieldOrder:
+				if domain.OrderDateTime == "" {
+					domain.OrderDateTime, err = dayTimestamp(value, layouts, opts.Location)
+				}
+			case fieldRegistration:
+				if domain.RegistrationDateTime == "" {
+					domain.RegistrationDateTime, err = dayTimestamp(value, layouts, opts.Location)
+				}
+			case fieldDelete:
+				if domain.DeleteDateTime == "" {
+					domain.DeleteDateTime, err = dayTimestamp(value, layouts, opts.Location)
+				}
+			}
+			if err != nil {
+				return snap, fmt.Errorf("row %d, column %q: %w", line+2, rows[0][i], err)
+			}
+		}
+		snap.Domains = append(snap.Domains, domain)
+	}
+	return snap, nil
+}
+
+// columnKeyForLabel finds the column of a header label: a configured label,
+// a default label or a column key, all case insensitive. It returns an
+// empty string for columns a snapshot does not need.
+func columnKeyForLabel(label string, configured []column) string {
+	label = strings.TrimSpace(label)
+	for _, col := range configured {
+		if strings.EqualFold(col.Label, label) {
+			return col.Key
+		}
+	}
+	for _, col := range availableColumns {
+		if strings.EqualFold(col.Label, label) || strings.EqualFold(col.Key, label) {
+			return col.Key
+		}
+	}
+	return ""
+}
+
+// dayTimestamp parses a date cell with the first matching layout and returns
+// the start of that day in loc as API timestamp
+func dayTimestamp(value string, layouts []string, loc *time.Location) (string, error) {
+	for _, layout := range layouts {
+		if day, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return day.Format(time.RFC3339), nil
+		}
+	}
+	return "", fmt.Errorf("unreadable date %q", value)
+}
+
+// fetchSnapshot fetches the current inventory from the API, the domains not
+// deleted by today in loc as in a default export. Exports read back with
+// readSnapshot already are such an inventory, stored snapshots are reduced
+// to one the same way.
+func fetchSnapshot(ctx context.Context, client *nicmanager.Client, loc *time.Location) (snapshot, error) {
+	snap := snapshot{Fields: allFields}
+	cutoff := inventoryCutoff(time.Now(), loc)
+	for apiDomain, err := range client.Domains(ctx, nicmanager.DefaultPageSize) {
+		if err != nil {
+			return snap, err
+		}
+		domain := Domain(apiDomain)
+		if domain.IsBelowCutoff(cutoff) {
+			snap.Domains = append(snap.Domains, domain)
+		}
+	}
+	return snap, nil
+}
+
+// inventoryCutoff returns the cutoff of a default export made at t, the end
+// of the day of t in loc
+func inventoryCutoff(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	day := t.In(loc)
+	return time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc).Add(-time.Nanosecond)
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mariow/nicmanager-export/nicmanager"
+)
+
+// snapshotExt is the extension of stored snapshots, gzip compressed NDJSON
+// with one API record per line
+const snapshotExt = ".ndjson.gz"
+
+// snapshotIDLayout formats the fetch time in UTC as snapshot ID, which is
+// also the file name without extension
+const snapshotIDLayout = "20060102T150405Z"
+
+// latestSnapshot selects the newest snapshot wherever an ID is expected
+const latestSnapshot = "latest"
+
+// snapshotRefPrefix marks a stored snapshot where a file name is expected,
+// e.g. snapshot:latest
+const snapshotRefPrefix = "snapshot:"
+
+// snapshotSettings configure the snapshot store in the config file
+type snapshotSettings struct {
+	// Dir is the store directory, empty means defaultSnapshotDir
+	Dir string `toml:"dir"`
+	// Disabled turns off saving a snapshot for every export
+	Disabled bool `toml:"disabled"`
+	// Keep and KeepDays are the retention policy applied after every saved
+	// snapshot, see retentionPolicy
+	Keep     int `toml:"keep"`
+	KeepDays int `toml:"keep_days"`
+}
+
+// retention returns the configured retention policy
+func (s *snapshotSettings) retention() retentionPolicy {
+	return retentionPolicy{Keep: s.Keep, KeepDays: s.KeepDays}
+}
+
+// defaultSnapshotDir returns the snapshot directory in the user data
+// directory, e.g. ~/.local/share/nicmanager-export/snapshots on Linux
+func defaultSnapshotDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, configDirName, "snapshots"), nil
+	}
+
+	var base string
+	switch runtime.GOOS {
+	case "windows":
+		base = os.Getenv("LocalAppData")
+		if base == "" {
+			return "", errors.New("%LocalAppData% is not defined")
+		}
+	case "darwin", "ios":
+		// ~/Library/Application Support
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		base = dir
+	default:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(base, configDirName, "snapshots"), nil
+}
+
+// snapshotStore keeps the domain list of every export as snapshot file
+type snapshotStore struct {
+	dir string
+}
+
+// openSnapshotStore returns the store in dir, or in the default directory if
+// dir is empty. The directory is created with the first snapshot.
+func openSnapshotStore(dir string) (*snapshotStore, error) {
+	if dir == "" {
+		var err error
+		if dir, err = defaultSnapshotDir(); err != nil {
+			return nil, fmt.Errorf("snapshot directory: %w", err)
+		}
+	}
+	return &snapshotStore{dir: dir}, nil
+}
+
+// snapshotInfo describes a stored snapshot
+type snapshotInfo struct {
+	ID   string
+	Time time.Time
+	Path string
+	Size int64
+}
+
+// list returns all snapshots, newest first. A missing directory is an empty
+// store.
+func (s *snapshotStore) list() ([]snapshotInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []snapshotInfo
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), snapshotExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		fetched, ok := snapshotIDTime(id)
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshotInfo{ID: id, Time: fetched, Path: filepath.Join(s.dir, entry.Name()), Size: info.Size()})
+	}
+
+	slices.SortFunc(snapshots, func(a, b snapshotInfo) int {
+		return cmp.Or(b.Time.Compare(a.Time), strings.Compare(b.ID, a.ID))
+	})
+	return snapshots, nil
+}
+
+// lookup returns the snapshot with the given ID, or the newest one for
+// latestSnapshot
+func (s *snapshotStore) lookup(id string) (snapshotInfo, error) {
+	snapshots, err := s.list()
+	if err != nil {
+		return snapshotInfo{}, err
+	}
+	if id == latestSnapshot && len(snapshots) > 0 {
+		return snapshots[0], nil
+	}
+	for _, snap := range snapshots {
+		if snap.ID == id {
+			return snap, nil
+		}
+	}
+	return snapshotInfo{}, fmt.Errorf("no snapshot %q in %s", id, s.dir)
+}
+
+// create starts a new snapshot fetched at now. The domains are written to a
+// temporary file that commit moves into place.
+func (s *snapshotStore) create(now time.Time) (*snapshotRecorder, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(s.dir, ".snapshot-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(file)
+	return &snapshotRecorder{
+		store:   s,
+		fetched: now.UTC(),
+		file:    file,
+		gz:      gz,
+		encoder: json.NewEncoder(gz),
+	}, nil
+}
+
+// snapshotRecorder writes the domains of one fetch into a new snapshot
+type snapshotRecorder struct {
+	store   *snapshotStore
+	fetched time.Time
+	file    *os.File
+	gz      *gzip.Writer
+	encoder *json.Encoder
+	// err is the first write error, reported by commit
+	err error
+}
+
+// add appends a domain to the snapshot
+func (r *snapshotRecorder) add(domain *Domain) {
+	if r.err == nil {
+		r.err = r.encoder.Encode(domain)
+	}
+}
+
+// commit finishes the snapshot and stores it under its ID
+func (r *snapshotRecorder) commit() (snapshotInfo, error) {
+	err := errors.Join(r.err, r.gz.Close(), r.file.Sync(), r.file.Close())
+	if err != nil {
+		os.Remove(r.file.Name())
+		return snapshotInfo{}, err
+	}
+
+	id := r.fetched.Format(snapshotIDLayout)
+	path := filepath.Join(r.store.dir, id+snapshotExt)
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", r.fetched.Format(snapshotIDLayout), n)
+		path = filepath.Join(r.store.dir, id+snapshotExt)
+	}
+	if err := os.Rename(r.file.Name(), path); err != nil {
+		os.Remove(r.file.Name())
+		return snapshotInfo{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return snapshotInfo{}, err
+	}
+	return snapshotInfo{ID: id, Time: r.fetched, Path: path, Size: info.Size()}, nil
+}
+
+// discard drops an unfinished snapshot, nil-safe
+func (r *snapshotRecorder) discard() {
+	if r == nil {
+		return
+	}
+	r.gz.Close()
+	r.file.Close()
+	os.Remove(r.file.Name())
+}
+
+// retentionPolicy decides which snapshots prune removes. Keep is the number
+// of newest snapshots to keep, KeepDays removes snapshots older than that
+// many days; zero disables the respective rule. A snapshot is removed if any
+// rule removes it, but the newest snapshot is always kept.
+type retentionPolicy struct {
+	Keep     int
+	KeepDays int
+}
+
+// isSet reports whether the policy removes anything at all
+func (p retentionPolicy) isSet() bool {
+	return p.Keep > 0 || p.KeepDays > 0
+}
+
+// expired returns the snapshots the policy removes at now, snapshots are
+// expected newest first as returned by list
+func (p retentionPolicy) expired(snapshots []snapshotInfo, now time.Time) []snapshotInfo {
+	var expired []snapshotInfo
+	for i, snap := range snapshots {
+		if i == 0 {
+			continue
+		}
+		tooMany := p.Keep > 0 && i >= p.Keep
+		tooOld := p.KeepDays > 0 && snap.Time.Before(now.AddDate(0, 0, -p.KeepDays))
+		if tooMany || tooOld {
+			expired = append(expired, snap)
+		}
+	}
+	return expired
+}
+
+// prune removes the snapshots expired by policy and returns them
+func (s *snapshotStore) prune(policy retentionPolicy, now time.Time) ([]snapshotInfo, error) {
+	snapshots, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	expired := policy.expired(snapshots, now)
+	for i, snap := range expired {
+		if err := os.Remove(snap.Path); err != nil {
+			return expired[:i], err
+		}
+	}
+	return expired, nil
+}
+
+// snapshotDomains iterates over the domains of a snapshot file, like
+// nicmanager.Client.Domains does over the API
+func snapshotDomains(path string) iter.Seq2[nicmanager.Domain, error] {
+	return func(yield func(nicmanager.Domain, error) bool) {
+		file, err := os.Open(path)
+		if err != nil {
+			yield(nicmanager.Domain{}, err)
+			return
+		}
+		defer file.Close()
+
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			yield(nicmanager.Domain{}, fmt.Errorf("%s: %w", path, err))
+			return
+		}
+		decoder := json.NewDecoder(gz)
+		for {
+			var domain nicmanager.Domain
+			err := decoder.Decode(&domain)
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(nicmanager.Domain{}, fmt.Errorf("%s: %w", path, err))
+				return
+			}
+			if !yield(domain, nil) {
+				return
+			}
+		}
+	}
+}
+
+// snapshotIDTime returns the fetch time of the snapshot with ID id, IDs of
+// snapshots saved in the same second get a suffix
+func snapshotIDTime(id string) (time.Time, bool) {
+	fetched, err := time.Parse(snapshotIDLayout, id[:min(len(id), len(snapshotIDLayout))])
+	return fetched, err == nil
+}
+
+// readStoredSnapshot reads a snapshot file for a diff. The snapshot holds the
+// whole fetched list, so like the current inventory of fetchSnapshot it is
+// reduced to the domains not deleted by the day it was fetched in loc.
+func readStoredSnapshot(path string, fetched time.Time, loc *time.Location) (snapshot, error) {
+	snap := snapshot{Fields: allFields}
+	cutoff := inventoryCutoff(fetched, loc)
+	for apiDomain, err := range snapshotDomains(path) {
+		if err != nil {
+			return snap, err
+		}
+		domain := Domain(apiDomain)
+		if domain.IsBelowCutoff(cutoff) {
+			snap.Domains = append(snap.Domains, domain)
+		}
+	}
+	return snap, nil
+}
+
+// snapshotSummary are the counts shown when inspecting a snapshot
+type snapshotSummary struct {
+	Records int
+	Deleted int
+	ByTLD   map[string]int
+	ByState map[string]int
+}
+
+// summarizeSnapshot counts the domains of a snapshot file
+func summarizeSnapshot(path string) (snapshotSummary, error) {
+	summary := snapshotSummary{ByTLD: make(map[string]int), ByState: make(map[string]int)}
+	for apiDomain, err := range snapshotDomains(path) {
+		if err != nil {
+			return summary, err
+		}
+		domain := Domain(apiDomain)
+		summary.Records++
+		if domain.DeleteDateTime != "" {
+			summary.Deleted++
+		}
+		summary.ByTLD[domain.TLD()]++
+		summary.ByState[domain.OrderStatus]++
+	}
+	return summary, nil
+}
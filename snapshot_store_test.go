@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storeSnapshot stores the domains of records as snapshot fetched at fetched
+func storeSnapshot(t *testing.T, store *snapshotStore, fetched time.Time, records []*exportRecord) snapshotInfo {
+	recorder, err := store.create(fetched)
+	require.NoError(t, err)
+	for _, record := range records {
+		recorder.add(&record.Domain)
+	}
+	info, err := recorder.commit()
+	require.NoError(t, err)
+	return info
+}
+
+func TestDefaultSnapshotDir(t *testing.T) {
+	dir := isolateConfig(t)
+
+	snapshotDir, err := defaultSnapshotDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, configDirName, "snapshots"), snapshotDir)
+}
+
+func TestSnapshotStore(t *testing.T) {
+	store, err := openSnapshotStore(filepath.Join(t.TempDir(), "snapshots"))
+	require.NoError(t, err)
+
+	snapshots, err := store.list()
+	require.NoError(t, err)
+	assert.Empty(t, snapshots, "A missing directory is an empty store")
+
+	fetched := time.Date(2024, 2, 1, 10, 30, 0, 0, time.UTC)
+	first := storeSnapshot(t, store, fetched.AddDate(0, -1, 0), testRecords(t))
+	second := storeSnapshot(t, store, fetched, testRecords(t)[:1])
+	third := storeSnapshot(t, store, fetched, testRecords(t))
+
+	assert.Equal(t, "20240101T103000Z", first.ID)
+	assert.Equal(t, "20240201T103000Z", second.ID)
+	assert.Equal(t, "20240201T103000Z-2", third.ID, "A snapshot of the same second should get a suffix")
+	assert.FileExists(t, filepath.Join(store.dir, "20240101T103000Z.ndjson.gz"))
+
+	snapshots, err = store.list()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 3)
+	assert.Equal(t, []string{third.ID, second.ID, first.ID}, []string{snapshots[0].ID, snapshots[1].ID, snapshots[2].ID}, "Newest first")
+	assert.Equal(t, fetched, snapshots[1].Time)
+	assert.Positive(t, snapshots[1].Size)
+
+	latest, err := store.lookup(latestSnapshot)
+	require.NoError(t, err)
+	assert.Equal(t, third.ID, latest.ID)
+
+	found, err := store.lookup(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.Path, found.Path)
+
+	_, err = store.lookup("20200101T000000Z")
+	assert.ErrorContains(t, err, `no snapshot "20200101T000000Z"`)
+}
+
+func TestSnapshotStore_Discard(t *testing.T) {
+	store := &snapshotStore{dir: t.TempDir()}
+
+	recorder, err := store.create(time.Now())
+	require.NoError(t, err)
+	recorder.add(&testRecords(t)[0].Domain)
+	recorder.discard()
+
+	entries, err := os.ReadDir(store.dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "A discarded snapshot should leave no file")
+}
+
+func TestExportDomainsToFile_DiscardsSnapshot(t *testing.T) {
+	tests := []struct {
+		name   string
+		format outputFormat
+		output string
+	}{
+		{"file not created", formatCSV, filepath.Join(t.TempDir(), "missing", "export.csv")},
+		{"writer not created", outputFormat("txt"), filepath.Join(t.TempDir(), "export.txt")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &snapshotStore{dir: t.TempDir()}
+			recorder, err := store.create(time.Now())
+			require.NoError(t, err)
+
+			_, err = exportDomainsToFile(snapshotDomains(""), exportOptions{Format: tt.format, Snapshot: recorder}, tt.output)
+			require.Error(t, err)
+
+			entries, err := os.ReadDir(store.dir)
+			require.NoError(t, err)
+			assert.Empty(t, entries, "An export that cannot write its file should store no snapshot")
+		})
+	}
+}
+
+func TestSnapshotDomains(t *testing.T) {
+	store := &snapshotStore{dir: t.TempDir()}
+	records := testRecords(t)
+	records[0].Domain.Extra = map[string]json.RawMessage{"auth_code_status": json.RawMessage(`"locked"`)}
+	info := storeSnapshot(t, store, time.Now(), records)
+
+	var names []string
+	for domain, err := range snapshotDomains(info.Path) {
+		require.NoError(t, err)
+		names = append(names, domain.Name)
+		if domain.Name == "example.com" {
+			assert.JSONEq(t, `"locked"`, string(domain.Extra["auth_code_status"]), "Unknown API fields should be kept")
+		}
+	}
+	assert.Equal(t, []string{"example.com", "müller.de"}, names)
+
+	snap, err := readSnapshot(snapshotRefPrefix+latestSnapshot, readOptions{Store: store})
+	require.NoError(t, err)
+	require.Len(t, snap.Domains, 1, "A diff should only see the inventory at the fetch time, müller.de was deleted before")
+	assert.Equal(t, "example.com", snap.Domains[0].Name)
+	assert.Equal(t, allFields, snap.Fields)
+
+	older := storeSnapshot(t, store, time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC), records)
+	snap, err = readSnapshot(snapshotRefPrefix+older.ID, readOptions{Store: store})
+	require.NoError(t, err)
+	assert.Len(t, snap.Domains, 2, "müller.de was still in the inventory when the older snapshot was fetched")
+	snap, err = readSnapshot(older.Path, readOptions{})
+	require.NoError(t, err)
+	assert.Len(t, snap.Domains, 2, "The fetch time of a snapshot file is taken from its name")
+
+	path := filepath.Join(t.TempDir(), "broken"+snapshotExt)
+	require.NoError(t, os.WriteFile(path, []byte("not gzip"), 0o600))
+	for _, err := range snapshotDomains(path) {
+		assert.Error(t, err)
+	}
+}
+
+func TestSummarizeSnapshot(t *testing.T) {
+	store := &snapshotStore{dir: t.TempDir()}
+	info := storeSnapshot(t, store, time.Now(), testRecords(t))
+
+	summary, err := summarizeSnapshot(info.Path)
+	require.NoError(t, err)
+	assert.Equal(t, snapshotSummary{
+		Records: 2,
+		Deleted: 1,
+		ByTLD:   map[string]int{"com": 1, "de": 1},
+		ByState: map[string]int{"active": 1, "deleted": 1},
+	}, summary)
+}
+
+func TestRetentionPolicy_Expired(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var snapshots []snapshotInfo
+	for _, daysAgo := range []int{0, 10, 40, 100, 400} {
+		fetched := now.AddDate(0, 0, -daysAgo)
+		snapshots = append(snapshots, snapshotInfo{ID: fetched.Format(snapshotIDLayout), Time: fetched})
+	}
+	ids := func(infos []snapshotInfo) []string {
+		var ids []string
+		for _, info := range infos {
+			ids = append(ids, info.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name     string
+		policy   retentionPolicy
+		expected []string
+	}{
+		{"no policy", retentionPolicy{}, nil},
+		{"keep count", retentionPolicy{Keep: 3}, ids(snapshots[3:])},
+		{"keep days", retentionPolicy{KeepDays: 40}, ids(snapshots[3:])},
+		{"a snapshot exactly as old as the limit stays", retentionPolicy{KeepDays: 100}, ids(snapshots[4:])},
+		{"either rule removes", retentionPolicy{Keep: 4, KeepDays: 30}, ids(snapshots[2:])},
+		{"the newest snapshot is always kept", retentionPolicy{KeepDays: 1}, ids(snapshots[1:])},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ids(tt.policy.expired(snapshots, now)))
+		})
+	}
+	assert.Equal(t, ids(snapshots[4:]), ids(retentionPolicy{Keep: 4}.expired(snapshots, now)))
+	assert.Empty(t, retentionPolicy{Keep: 1}.expired(snapshots[:1], now), "The newest snapshot is always kept")
+}
+
+func TestSnapshotStore_Prune(t *testing.T) {
+	store := &snapshotStore{dir: t.TempDir()}
+	now := time.Now()
+	old := storeSnapshot(t, store, now.AddDate(0, 0, -30), testRecords(t))
+	storeSnapshot(t, store, now, testRecords(t))
+
+	removed, err := store.prune(retentionPolicy{KeepDays: 7}, now)
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	assert.Equal(t, old.ID, removed[0].ID)
+	assert.NoFileExists(t, old.Path)
+
+	snapshots, err := store.list()
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeExportFile writes records in format to a file in a temporary directory
+func writeExportFile(t *testing.T, format outputFormat, opts exportOptions, records []*exportRecord) string {
+	path := filepath.Join(t.TempDir(), "export."+string(format))
+	if format == formatSQLite {
+		writeSQLite(t, path, opts, records)
+		return path
+	}
+	require.NoError(t, os.WriteFile(path, writeAll(t, format, opts, records), 0o600))
+	return path
+}
+
+func TestReadSnapshot_AllFormats(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	statusColumns, err := parseColumns("order_status=Status,name=Domainname,delete_date,registration_date")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		format   outputFormat
+		opts     exportOptions
+		fields   []string
+		statuses []string
+	}{
+		{"CSV", formatCSV, exportOptions{}, []string{fieldOrder, fieldRegistration, fieldDelete}, []string{"", ""}},
+		{"CSV with status column", formatCSV, exportOptions{Columns: statusColumns}, []string{fieldStatus, fieldRegistration, fieldDelete}, []string{"active", "deleted"}},
+		{"CSV de-DE Excel", formatCSV, exportOptions{CSV: csvPresets["de-de-excel"]}, []string{fieldOrder, fieldRegistration, fieldDelete}, []string{"", ""}},
+		{"XLSX", formatXLSX, exportOptions{Columns: statusColumns}, []string{fieldStatus, fieldRegistration, fieldDelete}, []string{"active", "deleted"}},
+		{"ODS", formatODS, exportOptions{}, []string{fieldOrder, fieldRegistration, fieldDelete}, []string{"", ""}},
+		{"JSON", formatJSON, exportOptions{}, diffFields, []string{"active", "deleted"}},
+		{"NDJSON", formatNDJSON, exportOptions{}, diffFields, []string{"active", "deleted"}},
+		{"SQLite", formatSQLite, exportOptions{}, diffFields, []string{"active", "deleted"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Location = berlin
+			path := writeExportFile(t, tt.format, tt.opts, testRecords(t))
+
+			snap, err := readSnapshot(path, readOptions{Location: berlin, Columns: tt.opts.Columns})
+			require.NoError(t, err)
+
+			var fields []string
+			for _, field := range diffFields {
+				if snap.Fields[field] {
+					fields = append(fields, field)
+				}
+			}
+			assert.Equal(t, tt.fields, fields)
+
+			require.Len(t, snap.Domains, 2)
+			assert.Equal(t, "example.com", snap.Domains[0].Name)
+			assert.Equal(t, "müller.de", snap.Domains[1].Name)
+			assert.Equal(t, tt.statuses, []string{snap.Domains[0].OrderStatus, snap.Domains[1].OrderStatus})
+
+			// the deletion at 23:30 UTC is on New Year's Day in Berlin
+			values := diffValues(&snap.Domains[1], berlin)
+			assert.Equal(t, "2024-01-01", values[fieldDelete])
+			assert.Equal(t, "2022-01-02", values[fieldRegistration])
+			assert.Equal(t, "", diffValues(&snap.Domains[0], berlin)[fieldDelete])
+		})
+	}
+}
+
+func TestReadSnapshot_CustomDateFormat(t *testing.T) {
+	dialect, err := csvSettings{Preset: "de-DE Excel", DateFormat: "DD.MM.YY"}.dialect()
+	require.NoError(t, err)
+	path := writeExportFile(t, formatCSV, exportOptions{CSV: dialect}, testRecords(t))
+
+	_, err = readSnapshot(path, readOptions{})
+	assert.ErrorContains(t, err, `unreadable date "01.01.23"`)
+
+	snap, err := readSnapshot(path, readOptions{DateLayout: dialect.DateLayout})
+	require.NoError(t, err)
+	assert.Equal(t, "2023-01-01", diffValues(&snap.Domains[0], nil)[fieldOrder])
+}
+
+func TestReadSnapshot_TimestampColumns(t *testing.T) {
+	columns, err := parseColumns("name,delete_date,delete_datetime")
+	require.NoError(t, err)
+	path := writeExportFile(t, formatCSV, exportOptions{Columns: columns}, testRecords(t))
+
+	snap, err := readSnapshot(path, readOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "2023-12-31T23:30:00Z", snap.Domains[1].DeleteDateTime, "The raw timestamp should win over the date column")
+}
+
+func TestReadSnapshot_Errors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"unknown extension", write("export.txt", "Domain\n"), "unknown export format"},
+		{"missing file", filepath.Join(dir, "missing.csv"), "missing.csv"},
+		{"missing SQLite file", filepath.Join(dir, "missing.sqlite"), "missing.sqlite"},
+		{"empty CSV", write("empty.csv", ""), "header is missing"},
+		{"no name column", write("noname.csv", "TLD,Order Date\ncom,2023-01-01\n"), "no domain name column"},
+		{"invalid JSON", write("export.json", `{"name":"example.com"}`), "export.json"},
+		{"invalid NDJSON line", write("export.ndjson", "{\"name\":\"example.com\"}\n[\n"), "line 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readSnapshot(tt.path, readOptions{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	assert.Equal(t, ',', detectDelimiter([]byte("Domain,Order Date\nexample.com;x\n")))
+	assert.Equal(t, ';', detectDelimiter([]byte("Domain;Order Date;Reg Date\n")))
+	assert.Equal(t, '\t', detectDelimiter([]byte("Domain\tOrder Date\n")))
+	assert.Equal(t, ',', detectDelimiter([]byte("Domain\n")))
+}
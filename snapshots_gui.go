@@ -0,0 +1,134 @@
+//go:build !test && !nogui
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showSnapshotsWindow opens the window listing the stored snapshots with
+// their details and pruning old ones by policy; changed is called after
+// snapshots were removed
+func showSnapshotsWindow(a fyne.App, store *snapshotStore, policy retentionPolicy, changed func()) {
+	w := a.NewWindow("Snapshots")
+
+	var snapshots []snapshotInfo
+	details := widget.NewLabel("")
+	details.Wrapping = fyne.TextWrapWord
+
+	list := widget.NewList(
+		func() int { return len(snapshots) },
+		func() fyne.CanvasObject { return widget.NewLabel("02.01.2006 15:04:05 (1234 kB)") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			snap := snapshots[id]
+			item.(*widget.Label).SetText(fmt.Sprintf("%s (%d kB)", snap.Time.Local().Format("02.01.2006 15:04:05"), (snap.Size+1023)/1024))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		summary, err := summarizeSnapshot(snapshots[id].Path)
+		if err != nil {
+			log.Println(err)
+			details.SetText(userMessage(err))
+			return
+		}
+		details.SetText(snapshotSummaryText(snapshots[id], summary))
+	}
+
+	reload := func() {
+		var err error
+		if snapshots, err = store.list(); err != nil {
+			dialog.ShowError(err, w)
+		}
+		list.UnselectAll()
+		list.Refresh()
+		details.SetText(fmt.Sprintf("%d Snapshots in %s", len(snapshots), store.dir))
+	}
+
+	uiKeep := widget.NewEntry()
+	uiKeep.SetPlaceHolder("Anzahl")
+	if policy.Keep > 0 {
+		uiKeep.SetText(strconv.Itoa(policy.Keep))
+	}
+	uiKeepDays := widget.NewEntry()
+	uiKeepDays.SetPlaceHolder("Tage")
+	if policy.KeepDays > 0 {
+		uiKeepDays.SetText(strconv.Itoa(policy.KeepDays))
+	}
+
+	uiPrune := widget.NewButton("Aufräumen", func() {
+		var prune retentionPolicy
+		for _, field := range []struct {
+			entry *widget.Entry
+			value *int
+		}{{uiKeep, &prune.Keep}, {uiKeepDays, &prune.KeepDays}} {
+			if field.entry.Text == "" {
+				continue
+			}
+			n, err := strconv.Atoi(field.entry.Text)
+			if err != nil || n < 0 {
+				dialog.ShowError(fmt.Errorf("%q ist keine gültige Zahl", field.entry.Text), w)
+				return
+			}
+			*field.value = n
+		}
+		if !prune.isSet() {
+			dialog.ShowInformation("Aufräumen", "Bitte angeben, wie viele Snapshots oder wie viele Tage behalten werden sollen", w)
+			return
+		}
+
+		expired := prune.expired(snapshots, time.Now())
+		if len(expired) == 0 {
+			dialog.ShowInformation("Aufräumen", "Es gibt keine Snapshots zum Löschen", w)
+			return
+		}
+		dialog.ShowConfirm("Aufräumen", fmt.Sprintf("%d Snapshots löschen?", len(expired)), func(ok bool) {
+			if !ok {
+				return
+			}
+			if _, err := store.prune(prune, time.Now()); err != nil {
+				dialog.ShowError(err, w)
+			}
+			reload()
+			changed()
+		}, w)
+	})
+
+	prune := container.NewHBox(widget.NewLabel("Behalten:"), uiKeep, uiKeepDays, uiPrune)
+	w.SetContent(container.NewBorder(nil, container.NewVBox(details, prune), nil, nil, list))
+	reload()
+	w.Resize(fyne.NewSize(500, 500))
+	w.Show()
+}
+
+// snapshotSummaryText describes a snapshot for the details label
+func snapshotSummaryText(snap snapshotInfo, summary snapshotSummary) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Snapshot %s vom %s\n", snap.ID, snap.Time.Local().Format("02.01.2006 15:04:05"))
+	fmt.Fprintf(&text, "%d Domains, davon %d gelöscht\n", summary.Records, summary.Deleted)
+	for _, counts := range []struct {
+		label  string
+		values map[string]int
+	}{{"TLDs", summary.ByTLD}, {"Status", summary.ByState}} {
+		var parts []string
+		for _, key := range slices.Sorted(maps.Keys(counts.values)) {
+			name := key
+			if name == "" {
+				name = "(leer)"
+			}
+			parts = append(parts, fmt.Sprintf("%s %d", name, counts.values[key]))
+		}
+		fmt.Fprintf(&text, "%s: %s\n", counts.label, strings.Join(parts, ", "))
+	}
+	return strings.TrimSuffix(text.String(), "\n")
+}
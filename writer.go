@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// exportRecord is a domain as handed to an ExportWriter, with its dates
+// already parsed
+type exportRecord struct {
+	Domain Domain
+	Dates  domainDates
+}
+
+// ExportWriter writes exported domains in one output format. WriteHeader is
+// called once before the first domain, Close once after the last one; Close
+// flushes buffered output but leaves the underlying io.Writer open.
+type ExportWriter interface {
+	WriteHeader() error
+	WriteDomain(record *exportRecord) error
+	Close() error
+}
+
+// partialFlusher is implemented by the streaming writers, it writes out the
+// buffered records of a failed export without finishing the file, for a
+// partial file kept for debugging
+type partialFlusher interface {
+	flushPartial() error
+}
+
+// aborter is implemented by the writers holding resources beyond the output,
+// like temporary files or a transaction, it releases them after a failed
+// export instead of Close
+type aborter interface {
+	abort()
+}
+
+// outputFormat names an output format, its value is also the file extension
+type outputFormat string
+
+const (
+	formatCSV    outputFormat = "csv"
+	formatJSON   outputFormat = "json"
+	formatNDJSON outputFormat = "ndjson"
+	formatXLSX   outputFormat = "xlsx"
+	formatODS    outputFormat = "ods"
+	formatSQLite outputFormat = "sqlite"
+)
+
+// outputFormats lists the supported formats in the order they are offered
+var outputFormats = []outputFormat{formatCSV, formatXLSX, formatODS, formatJSON, formatNDJSON, formatSQLite}
+
+// streams reports whether the format is written record by record, only then
+// the output of a failed export can be kept as partial file
+func (f outputFormat) streams() bool {
+	return f == formatCSV || f == formatJSON || f == formatNDJSON || f == ""
+}
+
+// parseOutputFormat checks a format name given by the user
+func parseOutputFormat(name string) (outputFormat, error) {
+	for _, format := range outputFormats {
+		if strings.EqualFold(name, string(format)) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q", name)
+}
+
+// formatFromPath derives the output format from the file extension of path
+func formatFromPath(path string) (outputFormat, bool) {
+	format, err := parseOutputFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	return format, err == nil
+}
+
+// newExportWriter creates the ExportWriter for format writing to out. SQLite
+// databases are written by exportDomainsToFile only, see openSQLiteExportWriter.
+func newExportWriter(format outputFormat, out io.Writer, opts exportOptions) (ExportWriter, error) {
+	switch format {
+	case formatCSV, "":
+		return newCSVExportWriter(out, opts), nil
+	case formatJSON:
+		return newJSONExportWriter(out), nil
+	case formatNDJSON:
+		return newNDJSONExportWriter(out), nil
+	case formatXLSX:
+		return newXLSXExportWriter(out, opts)
+	case formatODS:
+		return newODSExportWriter(out, opts), nil
+	case formatSQLite:
+		return nil, errors.New("sqlite output needs a file, it cannot be written to a stream")
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// csvExportWriter writes the selected columns as CSV in the dialect of the
+// export options, by default the classic four column file
+type csvExportWriter struct {
+	csvWriter *csvRecordWriter
+	opts      exportOptions
+	columns   []column
+	row       []string
+}
+
+func newCSVExportWriter(out io.Writer, opts exportOptions) *csvExportWriter {
+	columns := opts.tableColumns()
+	return &csvExportWriter{
+		csvWriter: newCSVRecordWriter(out, opts.csvDialect()),
+		opts:      opts,
+		columns:   columns,
+		row:       make([]string, len(columns)),
+	}
+}
+
+func (w *csvExportWriter) WriteHeader() error {
+	if err := w.csvWriter.writeBOM(); err != nil {
+		return err
+	}
+	return w.csvWriter.write(columnLabels(w.columns))
+}
+
+func (w *csvExportWriter) WriteDomain(record *exportRecord) error {
+	for i, col := range w.columns {
+		w.row[i] = formatCSVCell(col.value(record, &w.opts), w.opts.Location, w.csvWriter.dialect.DateLayout)
+	}
+	return w.csvWriter.write(w.row)
+}
+
+func (w *csvExportWriter) Close() error {
+	return w.csvWriter.flush()
+}
+
+func (w *csvExportWriter) flushPartial() error {
+	return w.csvWriter.flush()
+}
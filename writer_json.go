@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// jsonExportWriter writes an indented JSON array of domains, using the field
+// names and raw values of the API
+type jsonExportWriter struct {
+	out   *bufio.Writer
+	count int
+}
+
+func newJSONExportWriter(out io.Writer) *jsonExportWriter {
+	return &jsonExportWriter{out: bufio.NewWriter(out)}
+}
+
+func (w *jsonExportWriter) WriteHeader() error {
+	_, err := w.out.WriteString("[")
+	return err
+}
+
+func (w *jsonExportWriter) WriteDomain(record *exportRecord) error {
+	data, err := json.MarshalIndent(record.Domain, "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n  "
+	if w.count == 0 {
+		separator = "\n  "
+	}
+	w.count++
+
+	if _, err := w.out.WriteString(separator); err != nil {
+		return err
+	}
+	_, err = w.out.Write(data)
+	return err
+}
+
+func (w *jsonExportWriter) Close() error {
+	closing := "\n]\n"
+	if w.count == 0 {
+		closing = "]\n"
+	}
+	if _, err := w.out.WriteString(closing); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// flushPartial leaves the array open
+func (w *jsonExportWriter) flushPartial() error {
+	return w.out.Flush()
+}
+
+// ndjsonExportWriter writes one JSON object per line (newline delimited JSON),
+// suitable for streaming into jq and similar tools
+type ndjsonExportWriter struct {
+	out     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newNDJSONExportWriter(out io.Writer) *ndjsonExportWriter {
+	buffered := bufio.NewWriter(out)
+	return &ndjsonExportWriter{
+		out:     buffered,
+		encoder: json.NewEncoder(buffered),
+	}
+}
+
+// WriteHeader writes nothing, NDJSON has no header
+func (w *ndjsonExportWriter) WriteHeader() error {
+	return nil
+}
+
+func (w *ndjsonExportWriter) WriteDomain(record *exportRecord) error {
+	return w.encoder.Encode(record.Domain)
+}
+
+func (w *ndjsonExportWriter) Close() error {
+	return w.out.Flush()
+}
+
+func (w *ndjsonExportWriter) flushPartial() error {
+	return w.out.Flush()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+const odsMimeType = "application/vnd.oasis.opendocument.spreadsheet"
+
+const odsManifest = `<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">
+ <manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="` + odsMimeType + `"/>
+ <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>
+</manifest:manifest>
+`
+
+// odsContentStart opens content.xml up to the column definitions. N1 is an
+// ISO date format, ce1 the cell style using it, co1 and co2 are the widths of
+// the name and the other columns.
+const odsContentStart = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:number="urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0" office:version="1.2">
+<office:automatic-styles>
+<number:date-style style:name="N1"><number:year number:style="long"/><number:text>-</number:text><number:month number:style="long"/><number:text>-</number:text><number:day number:style="long"/></number:date-style>
+<style:style style:name="ce1" style:family="table-cell" style:data-style-name="N1"/>
+<style:style style:name="co1" style:family="table-column"><style:table-column-properties style:column-width="6cm"/></style:style>
+<style:style style:name="co2" style:family="table-column"><style:table-column-properties style:column-width="2.5cm"/></style:style>
+</office:automatic-styles>
+<office:body>
+<office:spreadsheet>
+<table:table table:name="Domains">
+`
+
+const odsContentEnd = `</table:table>
+</office:spreadsheet>
+</office:body>
+</office:document-content>
+`
+
+// odsExportWriter writes an OpenDocument spreadsheet with the selected
+// columns, dates as typed date cells and numbers as float cells. The rows are
+// streamed straight into the content.xml of the zip archive.
+type odsExportWriter struct {
+	archive *zip.Writer
+	content *bufio.Writer
+	opts    exportOptions
+	columns []column
+}
+
+func newODSExportWriter(out io.Writer, opts exportOptions) *odsExportWriter {
+	return &odsExportWriter{
+		archive: zip.NewWriter(out),
+		opts:    opts,
+		columns: opts.tableColumns(),
+	}
+}
+
+// WriteHeader writes the parts of the archive that precede the rows and the
+// header row itself. The mimetype has to be the first, uncompressed entry.
+func (w *odsExportWriter) WriteHeader() error {
+	mimetype, err := w.archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, odsMimeType); err != nil {
+		return err
+	}
+
+	manifest, err := w.archive.Create("META-INF/manifest.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(manifest, odsManifest); err != nil {
+		return err
+	}
+
+	content, err := w.archive.Create("content.xml")
+	if err != nil {
+		return err
+	}
+	w.content = bufio.NewWriter(content)
+	if _, err := w.content.WriteString(odsContentStart); err != nil {
+		return err
+	}
+
+	for _, col := range w.columns {
+		style := "co2"
+		if col.Key == "name" {
+			style = "co1"
+		}
+		w.content.WriteString(`<table:table-column table:style-name="` + style + `"/>`)
+	}
+
+	w.content.WriteString("\n<table:table-row>")
+	for _, label := range columnLabels(w.columns) {
+		w.writeStringCell(label)
+	}
+	_, err = w.content.WriteString("</table:table-row>\n")
+	return err
+}
+
+func (w *odsExportWriter) WriteDomain(record *exportRecord) error {
+	w.content.WriteString("<table:table-row>")
+	for _, col := range w.columns {
+		switch value := col.value(record, &w.opts).(type) {
+		case time.Time:
+			w.writeDateCell(value)
+		case int:
+			w.writeNumberCell(value)
+		case string:
+			if value == "" {
+				w.content.WriteString("<table:table-cell/>")
+			} else {
+				w.writeStringCell(value)
+			}
+		default:
+			w.content.WriteString("<table:table-cell/>")
+		}
+	}
+	// bufio.Writer keeps the first error, so checking the last write is enough
+	_, err := w.content.WriteString("</table:table-row>\n")
+	return err
+}
+
+func (w *odsExportWriter) writeStringCell(value string) {
+	w.content.WriteString(`<table:table-cell office:value-type="string"><text:p>`)
+	xml.EscapeText(w.content, []byte(value))
+	w.content.WriteString("</text:p></table:table-cell>")
+}
+
+// writeDateCell writes the calendar day in the export time zone as date cell,
+// a zero date as empty cell
+func (w *odsExportWriter) writeDateCell(date time.Time) {
+	if date.IsZero() {
+		w.content.WriteString("<table:table-cell/>")
+		return
+	}
+	day := formatDate(date, w.opts.Location)
+	w.content.WriteString(`<table:table-cell table:style-name="ce1" office:value-type="date" office:date-value="` + day + `"><text:p>` + day + "</text:p></table:table-cell>")
+}
+
+func (w *odsExportWriter) writeNumberCell(value int) {
+	number := strconv.Itoa(value)
+	w.content.WriteString(`<table:table-cell office:value-type="float" office:value="` + number + `"><text:p>` + number + "</text:p></table:table-cell>")
+}
+
+func (w *odsExportWriter) Close() error {
+	if _, err := w.content.WriteString(odsContentEnd); err != nil {
+		return err
+	}
+	if err := w.content.Flush(); err != nil {
+		return err
+	}
+	return w.archive.Close()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// odsContent is the part of content.xml the tests look at
+type odsContent struct {
+	XMLName xml.Name `xml:"urn:oasis:names:tc:opendocument:xmlns:office:1.0 document-content"`
+	Tables  []struct {
+		Name string `xml:"urn:oasis:names:tc:opendocument:xmlns:table:1.0 name,attr"`
+		Rows []struct {
+			Cells []odsCell `xml:"urn:oasis:names:tc:opendocument:xmlns:table:1.0 table-cell"`
+		} `xml:"urn:oasis:names:tc:opendocument:xmlns:table:1.0 table-row"`
+	} `xml:"body>spreadsheet>table"`
+}
+
+type odsCell struct {
+	ValueType string `xml:"urn:oasis:names:tc:opendocument:xmlns:office:1.0 value-type,attr"`
+	DateValue string `xml:"urn:oasis:names:tc:opendocument:xmlns:office:1.0 date-value,attr"`
+	Value     string `xml:"urn:oasis:names:tc:opendocument:xmlns:office:1.0 value,attr"`
+	StyleName string `xml:"urn:oasis:names:tc:opendocument:xmlns:table:1.0 style-name,attr"`
+	Text      string `xml:"urn:oasis:names:tc:opendocument:xmlns:text:1.0 p"`
+}
+
+// readZipEntry returns the content of the named entry of archive
+func readZipEntry(t *testing.T, archive *zip.Reader, name string) []byte {
+	entry, err := archive.Open(name)
+	require.NoError(t, err, "Archive should contain %s", name)
+	defer entry.Close()
+	data, err := io.ReadAll(entry)
+	require.NoError(t, err)
+	return data
+}
+
+// readODS unzips an exported spreadsheet, checks the package structure and
+// returns the parsed content.xml
+func readODS(t *testing.T, output []byte) odsContent {
+	archive, err := zip.NewReader(bytes.NewReader(output), int64(len(output)))
+	require.NoError(t, err, "Output should be a zip archive")
+
+	require.NotEmpty(t, archive.File)
+	mimetype := archive.File[0]
+	assert.Equal(t, "mimetype", mimetype.Name, "mimetype should be the first entry")
+	assert.Equal(t, zip.Store, mimetype.Method, "mimetype should not be compressed")
+	assert.Equal(t, odsMimeType, string(readZipEntry(t, archive, "mimetype")))
+
+	manifest := readZipEntry(t, archive, "META-INF/manifest.xml")
+	assert.Contains(t, string(manifest), `manifest:full-path="content.xml"`)
+
+	data := readZipEntry(t, archive, "content.xml")
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err, "content.xml should be well-formed")
+	}
+
+	var content odsContent
+	require.NoError(t, xml.Unmarshal(data, &content))
+	return content
+}
+
+func TestODSExportWriter(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	content := readODS(t, writeAll(t, formatODS, exportOptions{Location: berlin}, testRecords(t)))
+
+	require.Len(t, content.Tables, 1)
+	table := content.Tables[0]
+	assert.Equal(t, "Domains", table.Name)
+	require.Len(t, table.Rows, 3)
+
+	var header []string
+	for _, cell := range table.Rows[0].Cells {
+		assert.Equal(t, "string", cell.ValueType)
+		header = append(header, cell.Text)
+	}
+	assert.Equal(t, []string{"Domain", "Order Date", "Reg Date", "Close Date"}, header)
+
+	active := table.Rows[1].Cells
+	require.Len(t, active, 4)
+	assert.Equal(t, odsCell{ValueType: "string", Text: "example.com"}, active[0])
+	assert.Equal(t, odsCell{ValueType: "date", DateValue: "2023-01-01", StyleName: "ce1", Text: "2023-01-01"}, active[1])
+	assert.Equal(t, odsCell{ValueType: "date", DateValue: "2023-01-02", StyleName: "ce1", Text: "2023-01-02"}, active[2])
+	assert.Equal(t, odsCell{}, active[3], "A missing date should be an empty cell")
+
+	deleted := table.Rows[2].Cells
+	require.Len(t, deleted, 4)
+	assert.Equal(t, "müller.de", deleted[0].Text)
+	assert.Equal(t, "2024-01-01", deleted[3].DateValue, "Dates should be calendar days in the export time zone")
+}
+
+func TestODSExportWriter_Escaping(t *testing.T) {
+	record := &exportRecord{Domain: Domain{Name: `<b>&"x".de`}}
+
+	content := readODS(t, writeAll(t, formatODS, exportOptions{}, []*exportRecord{record}))
+
+	require.Len(t, content.Tables[0].Rows, 2)
+	assert.Equal(t, `<b>&"x".de`, content.Tables[0].Rows[1].Cells[0].Text)
+}
+
+func TestODSExportWriter_Empty(t *testing.T) {
+	content := readODS(t, writeAll(t, formatODS, exportOptions{}, nil))
+
+	require.Len(t, content.Tables, 1)
+	assert.Len(t, content.Tables[0].Rows, 1, "An empty export should only have the header row")
+}
+
+func TestODSExportWriter_Columns(t *testing.T) {
+	cutoff, err := parseCutoffDate("2024-01-31", time.UTC)
+	require.NoError(t, err)
+	columns, err := parseColumns("tld=Endung,days_in_portfolio,order_status")
+	require.NoError(t, err)
+
+	content := readODS(t, writeAll(t, formatODS, exportOptions{CutoffDate: cutoff, Columns: columns}, testRecords(t)))
+
+	rows := content.Tables[0].Rows
+	require.Len(t, rows, 3)
+	assert.Equal(t, "Endung", rows[0].Cells[0].Text)
+	assert.Equal(t, []odsCell{
+		{ValueType: "string", Text: "com"},
+		{ValueType: "float", Value: "394", Text: "394"},
+		{ValueType: "string", Text: "active"},
+	}, rows[1].Cells)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	// pure Go driver, so the tool still cross compiles without a C toolchain for SQLite
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables of the SQLite export. The primary key
+// doubles as index on the name, timestamps are stored in UTC as RFC 3339 so
+// the SQLite date functions work on them.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS export_runs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at  TEXT NOT NULL,
+	finished_at TEXT,
+	cutoff      TEXT NOT NULL,
+	time_zone   TEXT NOT NULL,
+	records     INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS domains (
+	name                  TEXT PRIMARY KEY,
+	tld                   TEXT NOT NULL,
+	order_status          TEXT NOT NULL,
+	order_datetime        TEXT,
+	registration_datetime TEXT,
+	delete_datetime       TEXT,
+	api_record            TEXT NOT NULL,
+	first_seen_run        INTEGER NOT NULL REFERENCES export_runs(id),
+	last_seen_run         INTEGER NOT NULL REFERENCES export_runs(id)
+);
+
+CREATE INDEX IF NOT EXISTS domains_tld ON domains(tld);
+CREATE INDEX IF NOT EXISTS domains_order_datetime ON domains(order_datetime);
+CREATE INDEX IF NOT EXISTS domains_registration_datetime ON domains(registration_datetime);
+CREATE INDEX IF NOT EXISTS domains_delete_datetime ON domains(delete_datetime);
+`
+
+const sqliteUpsert = `
+INSERT INTO domains (
+	name, tld, order_status, order_datetime, registration_datetime, delete_datetime,
+	api_record, first_seen_run, last_seen_run
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET
+	tld = excluded.tld,
+	order_status = excluded.order_status,
+	order_datetime = excluded.order_datetime,
+	registration_datetime = excluded.registration_datetime,
+	delete_datetime = excluded.delete_datetime,
+	api_record = excluded.api_record,
+	last_seen_run = excluded.last_seen_run
+`
+
+// sqliteExportWriter writes the complete domain records into a SQLite
+// database. An existing database is updated: domains are upserted by name
+// and every export adds a row to export_runs. All changes of one export are
+// a single transaction, a failed export leaves the database as it was.
+type sqliteExportWriter struct {
+	db     *sql.DB
+	tx     *sql.Tx
+	upsert *sql.Stmt
+	opts   exportOptions
+	runID  int64
+	count  int
+}
+
+// openSQLiteExportWriter opens or creates the database at path
+func openSQLiteExportWriter(path string, opts exportOptions) (*sqliteExportWriter, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// a single connection keeps the transaction and the schema on one handle
+	db.SetMaxOpenConns(1)
+
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	return &sqliteExportWriter{db: db, opts: opts}, nil
+}
+
+// WriteHeader creates the schema if needed and starts the export run
+func (w *sqliteExportWriter) WriteHeader() error {
+	if _, err := w.db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	w.tx = tx
+
+	run, err := tx.Exec(
+		"INSERT INTO export_runs (started_at, cutoff, time_zone) VALUES (?, ?, ?)",
+		sqliteTime(time.Now()),
+		w.opts.CutoffDate.In(w.opts.Location).Format("2006-01-02"),
+		w.opts.Location.String(),
+	)
+	if err != nil {
+		return err
+	}
+	if w.runID, err = run.LastInsertId(); err != nil {
+		return err
+	}
+
+	w.upsert, err = tx.Prepare(sqliteUpsert)
+	return err
+}
+
+func (w *sqliteExportWriter) WriteDomain(record *exportRecord) error {
+	apiRecord, err := json.Marshal(record.Domain)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.upsert.Exec(
+		record.Domain.Name,
+		record.Domain.TLD(),
+		record.Domain.OrderStatus,
+		sqliteTime(record.Dates.Order),
+		sqliteTime(record.Dates.Registration),
+		sqliteTime(record.Dates.Delete),
+		string(apiRecord),
+		w.runID,
+		w.runID,
+	)
+	if err != nil {
+		return err
+	}
+	w.count++
+	return nil
+}
+
+// Close finishes the export run, commits it and closes the database
+func (w *sqliteExportWriter) Close() error {
+	defer w.db.Close()
+
+	if _, err := w.tx.Exec(
+		"UPDATE export_runs SET finished_at = ?, records = ? WHERE id = ?",
+		sqliteTime(time.Now()), w.count, w.runID,
+	); err != nil {
+		w.tx.Rollback()
+		return err
+	}
+	return w.tx.Commit()
+}
+
+// abort rolls back everything written by a failed export
+func (w *sqliteExportWriter) abort() {
+	if w.tx != nil {
+		w.tx.Rollback()
+	}
+	w.db.Close()
+}
+
+// sqliteTime formats t for a TEXT column, the zero time becomes NULL
+func sqliteTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
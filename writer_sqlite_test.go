@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mariow/nicmanager-export/nicmanager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSQLite runs a complete export of records into the database at path
+func writeSQLite(t *testing.T, path string, opts exportOptions, records []*exportRecord) {
+	writer, err := openSQLiteExportWriter(path, opts)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.WriteHeader())
+	for _, record := range records {
+		require.NoError(t, writer.WriteDomain(record))
+	}
+	require.NoError(t, writer.Close())
+}
+
+// openTestDB opens an exported database for inspection
+func openTestDB(t *testing.T, path string) *sql.DB {
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type sqliteDomainRow struct {
+	Name, TLD, OrderStatus      string
+	Order, Registration, Delete sql.NullString
+	APIRecord                   string
+	FirstSeen, LastSeen         int64
+}
+
+func queryDomain(t *testing.T, db *sql.DB, name string) sqliteDomainRow {
+	var row sqliteDomainRow
+	err := db.QueryRow(`SELECT name, tld, order_status, order_datetime, registration_datetime,
+		delete_datetime, api_record, first_seen_run, last_seen_run FROM domains WHERE name = ?`, name).
+		Scan(&row.Name, &row.TLD, &row.OrderStatus, &row.Order, &row.Registration, &row.Delete,
+			&row.APIRecord, &row.FirstSeen, &row.LastSeen)
+	require.NoError(t, err, "domain %s", name)
+	return row
+}
+
+func countRows(t *testing.T, db *sql.DB, table string) int {
+	var count int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM "+table).Scan(&count))
+	return count
+}
+
+func TestSQLiteExportWriter(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	cutoff, err := parseCutoffDate("2024-01-31", berlin)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "export.sqlite")
+	writeSQLite(t, path, exportOptions{CutoffDate: cutoff, Location: berlin}, testRecords(t))
+
+	db := openTestDB(t, path)
+	assert.Equal(t, 2, countRows(t, db, "domains"))
+
+	active := queryDomain(t, db, "example.com")
+	assert.Equal(t, "com", active.TLD)
+	assert.Equal(t, "active", active.OrderStatus)
+	assert.Equal(t, sql.NullString{String: "2023-01-01T00:00:00Z", Valid: true}, active.Order)
+	assert.Equal(t, sql.NullString{String: "2023-01-02T00:00:00Z", Valid: true}, active.Registration)
+	assert.False(t, active.Delete.Valid, "A missing date should be NULL")
+
+	var apiRecord Domain
+	require.NoError(t, json.Unmarshal([]byte(active.APIRecord), &apiRecord))
+	assert.Equal(t, testRecords(t)[0].Domain, apiRecord, "api_record should keep the complete API record")
+
+	deleted := queryDomain(t, db, "müller.de")
+	assert.Equal(t, "de", deleted.TLD)
+	assert.Equal(t, "2023-12-31T23:30:00Z", deleted.Delete.String, "Timestamps should be stored in UTC")
+
+	var cutoffDay, timeZone string
+	var records int
+	var finished sql.NullString
+	require.NoError(t, db.QueryRow("SELECT cutoff, time_zone, records, finished_at FROM export_runs").
+		Scan(&cutoffDay, &timeZone, &records, &finished))
+	assert.Equal(t, "2024-01-31", cutoffDay)
+	assert.Equal(t, "Europe/Berlin", timeZone)
+	assert.Equal(t, 2, records)
+	assert.True(t, finished.Valid, "A finished run should have finished_at")
+}
+
+func TestSQLiteExportWriter_Upsert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.sqlite")
+	records := testRecords(t)
+	writeSQLite(t, path, exportOptions{}, records)
+
+	// the active domain got deleted in the meantime
+	changed := records[0].Domain
+	changed.OrderStatus = "deleted"
+	changed.DeleteDateTime = "2024-06-30T12:00:00Z"
+	dates, errs := changed.parseDates()
+	require.Empty(t, errs)
+	writeSQLite(t, path, exportOptions{}, []*exportRecord{{Domain: changed, Dates: dates}})
+
+	db := openTestDB(t, path)
+	assert.Equal(t, 2, countRows(t, db, "domains"), "Re-running should not duplicate domains")
+	assert.Equal(t, 2, countRows(t, db, "export_runs"))
+
+	updated := queryDomain(t, db, "example.com")
+	assert.Equal(t, "deleted", updated.OrderStatus)
+	assert.Equal(t, "2024-06-30T12:00:00Z", updated.Delete.String)
+	assert.Equal(t, int64(1), updated.FirstSeen)
+	assert.Equal(t, int64(2), updated.LastSeen)
+
+	untouched := queryDomain(t, db, "müller.de")
+	assert.Equal(t, int64(1), untouched.LastSeen, "A domain missing from the second run should keep its last run")
+}
+
+func TestSQLiteExportWriter_Abort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.sqlite")
+	records := testRecords(t)
+	writeSQLite(t, path, exportOptions{}, records[:1])
+
+	writer, err := openSQLiteExportWriter(path, exportOptions{})
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteHeader())
+	require.NoError(t, writer.WriteDomain(records[1]))
+	writer.abort()
+
+	db := openTestDB(t, path)
+	assert.Equal(t, 1, countRows(t, db, "domains"), "A failed export should leave the database as it was")
+	assert.Equal(t, 1, countRows(t, db, "export_runs"))
+}
+
+func TestExportDomainsToFile_SQLite(t *testing.T) {
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "maintenance", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`[{"name":"example.com","order_status":"active","order_datetime":"2023-01-01T00:00:00Z"}]`))
+	}))
+	defer server.Close()
+
+	client := nicmanager.NewClient("testuser", "testpass",
+		nicmanager.WithBaseURL(server.URL), nicmanager.WithRetryPolicy(nicmanager.NoRetry))
+	path := filepath.Join(t.TempDir(), "export.sqlite")
+	opts := exportOptions{CutoffDate: time.Now(), Format: formatSQLite}
+
+	result, err := exportDomainsToFile(client.Domains(context.Background(), nicmanager.DefaultPageSize), opts, path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.RecordsWritten)
+
+	fail = true
+	_, err = exportDomainsToFile(client.Domains(context.Background(), nicmanager.DefaultPageSize), opts, path)
+	require.Error(t, err)
+
+	db := openTestDB(t, path)
+	assert.Equal(t, 1, countRows(t, db, "domains"), "A failed export should keep the existing database")
+	assert.Equal(t, 1, countRows(t, db, "export_runs"))
+}
+
+func TestNewExportWriter_SQLiteNeedsFile(t *testing.T) {
+	_, err := newExportWriter(formatSQLite, &bytes.Buffer{}, exportOptions{})
+	assert.Error(t, err)
+}
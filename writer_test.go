@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRecords are two domains, one still active and one deleted
+func testRecords(t *testing.T) []*exportRecord {
+	domains := []Domain{
+		{
+			Name:                 "example.com",
+			OrderStatus:          "active",
+			OrderDateTime:        "2023-01-01T00:00:00Z",
+			RegistrationDateTime: "2023-01-02T00:00:00Z",
+		},
+		{
+			Name:                 "müller.de",
+			OrderStatus:          "deleted",
+			OrderDateTime:        "2022-01-01T00:00:00Z",
+			RegistrationDateTime: "2022-01-02T00:00:00Z",
+			DeleteDateTime:       "2023-12-31T23:30:00Z",
+		},
+	}
+
+	var records []*exportRecord
+	for _, domain := range domains {
+		dates, errs := domain.parseDates()
+		require.Empty(t, errs)
+		records = append(records, &exportRecord{Domain: domain, Dates: dates})
+	}
+	return records
+}
+
+// writeAll runs a complete export of records through the writer for format
+func writeAll(t *testing.T, format outputFormat, opts exportOptions, records []*exportRecord) []byte {
+	var out bytes.Buffer
+	writer, err := newExportWriter(format, &out, opts)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.WriteHeader())
+	for _, record := range records {
+		require.NoError(t, writer.WriteDomain(record))
+	}
+	require.NoError(t, writer.Close())
+	return out.Bytes()
+}
+
+func TestCSVExportWriter(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	output := writeAll(t, formatCSV, exportOptions{Location: berlin}, testRecords(t))
+
+	rows, err := csv.NewReader(bytes.NewReader(output)).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Domain", "Order Date", "Reg Date", "Close Date"},
+		{"example.com", "2023-01-01", "2023-01-02", ""},
+		{"müller.de", "2022-01-01", "2022-01-02", "2024-01-01"},
+	}, rows)
+}
+
+func TestCSVExportWriter_Columns(t *testing.T) {
+	columns, err := parseColumns("tld,name=Domainname,order_status,delete_date")
+	require.NoError(t, err)
+
+	output := writeAll(t, formatCSV, exportOptions{Columns: columns}, testRecords(t))
+
+	rows, err := csv.NewReader(bytes.NewReader(output)).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"TLD", "Domainname", "Order Status", "Close Date"},
+		{"com", "example.com", "active", ""},
+		{"de", "müller.de", "deleted", "2023-12-31"},
+	}, rows)
+}
+
+func TestJSONExportWriter(t *testing.T) {
+	output := writeAll(t, formatJSON, exportOptions{}, testRecords(t))
+
+	var domains []Domain
+	require.NoError(t, json.Unmarshal(output, &domains), "Output should be valid JSON: %s", output)
+	require.Len(t, domains, 2)
+	assert.Equal(t, testRecords(t)[1].Domain, domains[1], "JSON output should keep the API values")
+
+	assert.Contains(t, string(output), "[\n  {\n    \"name\": \"example.com\",", "Output should be indented")
+}
+
+func TestJSONExportWriter_Extra(t *testing.T) {
+	var domain Domain
+	require.NoError(t, json.Unmarshal([]byte(`{"name":"example.com","auth_code_status":"locked","nameservers":["ns1.example.net"]}`), &domain))
+	records := []*exportRecord{{Domain: domain}}
+
+	for _, format := range []outputFormat{formatJSON, formatNDJSON} {
+		output := writeAll(t, format, exportOptions{}, records)
+		assert.Contains(t, string(output), `"auth_code_status"`, "format %s", format)
+
+		var decoded Domain
+		if format == formatJSON {
+			var domains []Domain
+			require.NoError(t, json.Unmarshal(output, &domains))
+			require.Len(t, domains, 1)
+			decoded = domains[0]
+		} else {
+			require.NoError(t, json.Unmarshal(output, &decoded))
+		}
+		assert.Equal(t, domain, decoded, "format %s: unknown API fields should be kept", format)
+	}
+}
+
+func TestJSONExportWriter_Empty(t *testing.T) {
+	output := writeAll(t, formatJSON, exportOptions{}, nil)
+	assert.Equal(t, "[]\n", string(output))
+}
+
+func TestNDJSONExportWriter(t *testing.T) {
+	output := writeAll(t, formatNDJSON, exportOptions{}, testRecords(t))
+
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		var domain Domain
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &domain), "Every line should be a JSON object: %s", scanner.Text())
+		names = append(names, domain.Name)
+	}
+	assert.Equal(t, []string{"example.com", "müller.de"}, names)
+
+	assert.Empty(t, writeAll(t, formatNDJSON, exportOptions{}, nil), "An empty NDJSON export has no lines")
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		expected    outputFormat
+		expectError bool
+	}{
+		{"csv", formatCSV, false},
+		{"JSON", formatJSON, false},
+		{"ndjson", formatNDJSON, false},
+		{"XLSX", formatXLSX, false},
+		{"ods", formatODS, false},
+		{"sqlite", formatSQLite, false},
+		{"xml", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		format, err := parseOutputFormat(tt.name)
+		if tt.expectError {
+			assert.Error(t, err, "format %q", tt.name)
+		} else {
+			require.NoError(t, err, "format %q", tt.name)
+			assert.Equal(t, tt.expected, format)
+		}
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	tests := []struct {
+		path          string
+		expected      outputFormat
+		expectedFound bool
+	}{
+		{"Export_12345.csv", formatCSV, true},
+		{"/tmp/export.JSON", formatJSON, true},
+		{"export.ndjson", formatNDJSON, true},
+		{"Export_12345.xlsx", formatXLSX, true},
+		{"inventar.ods", formatODS, true},
+		{"inventar.sqlite", formatSQLite, true},
+		{"export.txt", "", false},
+		{"-", "", false},
+	}
+
+	for _, tt := range tests {
+		format, found := formatFromPath(tt.path)
+		assert.Equal(t, tt.expectedFound, found, "path %q", tt.path)
+		assert.Equal(t, tt.expected, format, "path %q", tt.path)
+	}
+}
+
+func TestNewExportWriter_UnknownFormat(t *testing.T) {
+	_, err := newExportWriter("xml", &bytes.Buffer{}, exportOptions{})
+	assert.EqualError(t, err, `unknown output format "xml"`)
+}
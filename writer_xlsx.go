@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	xlsxDomainSheet  = "Domains"
+	xlsxSummarySheet = "Summary"
+)
+
+// xlsxExportWriter writes an Excel workbook with the selected columns of the
+// domains on the first sheet, dates as real date cells, and summary counts on a second sheet.
+// Rows are streamed to a temporary file by excelize, the workbook is written
+// to out on Close.
+type xlsxExportWriter struct {
+	out       io.Writer
+	file      *excelize.File
+	stream    *excelize.StreamWriter
+	dateStyle int
+	opts      exportOptions
+	columns   []column
+
+	row     int
+	deleted int
+	byTLD   map[string]int
+	byState map[string]int
+}
+
+func newXLSXExportWriter(out io.Writer, opts exportOptions) (*xlsxExportWriter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName("Sheet1", xlsxDomainSheet); err != nil {
+		return nil, err
+	}
+
+	stream, err := file.NewStreamWriter(xlsxDomainSheet)
+	if err != nil {
+		return nil, err
+	}
+
+	// ISO dates, so the file reads the same whatever the locale of Excel is
+	dateFormat := "yyyy-mm-dd"
+	dateStyle, err := file.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	return &xlsxExportWriter{
+		out:       out,
+		file:      file,
+		stream:    stream,
+		dateStyle: dateStyle,
+		opts:      opts,
+		columns:   opts.tableColumns(),
+		byTLD:     map[string]int{},
+		byState:   map[string]int{},
+	}, nil
+}
+
+func (w *xlsxExportWriter) WriteHeader() error {
+	if err := w.stream.SetPanes(&excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+	for i, col := range w.columns {
+		width := 14.0
+		if col.Kind == columnText {
+			width = 24
+		}
+		if col.Key == "name" {
+			width = 40
+		}
+		if err := w.stream.SetColWidth(i+1, i+1, width); err != nil {
+			return err
+		}
+	}
+
+	header := make([]any, len(w.columns))
+	for i, label := range columnLabels(w.columns) {
+		header[i] = label
+	}
+	w.row = 1
+	return w.stream.SetRow("A1", header)
+}
+
+func (w *xlsxExportWriter) WriteDomain(record *exportRecord) error {
+	w.row++
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+
+	if !record.Dates.Delete.IsZero() {
+		w.deleted++
+	}
+	w.byTLD[record.Domain.TLD()]++
+	w.byState[record.Domain.OrderStatus]++
+
+	row := make([]any, len(w.columns))
+	for i, col := range w.columns {
+		value := col.value(record, &w.opts)
+		if date, ok := value.(time.Time); ok {
+			value = w.dateCell(date)
+		}
+		if value == "" {
+			value = nil
+		}
+		row[i] = value
+	}
+	return w.stream.SetRow(cell, row)
+}
+
+// dateCell turns a date into a date cell holding the calendar day in the
+// export time zone, a zero date into an empty cell
+func (w *xlsxExportWriter) dateCell(date time.Time) any {
+	if date.IsZero() {
+		return nil
+	}
+	local := date.In(w.opts.Location)
+	return excelize.Cell{
+		StyleID: w.dateStyle,
+		Value:   time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// abort removes the temporary files of the stream writer, which spills
+// large sheets to disk
+func (w *xlsxExportWriter) abort() {
+	w.file.Close()
+}
+
+func (w *xlsxExportWriter) Close() error {
+	defer w.file.Close()
+
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	lastCell, err := excelize.CoordinatesToCellName(len(w.columns), max(w.row, 1))
+	if err != nil {
+		return err
+	}
+	if err := w.file.AutoFilter(xlsxDomainSheet, "A1:"+lastCell, nil); err != nil {
+		return err
+	}
+
+	if err := w.writeSummary(); err != nil {
+		return err
+	}
+	return w.file.Write(w.out)
+}
+
+// writeSummary adds the sheet with the counts of the exported domains
+func (w *xlsxExportWriter) writeSummary() error {
+	if _, err := w.file.NewSheet(xlsxSummarySheet); err != nil {
+		return err
+	}
+
+	total := w.row - 1
+	rows := [][]any{
+		{"Domains", total},
+		{"Without Close Date", total - w.deleted},
+		{"With Close Date", w.deleted},
+		{},
+		{"TLD", "Domains"},
+	}
+	for _, tld := range slices.Sorted(maps.Keys(w.byTLD)) {
+		rows = append(rows, []any{tld, w.byTLD[tld]})
+	}
+	rows = append(rows, []any{}, []any{"Order Status", "Domains"})
+	for _, state := range slices.Sorted(maps.Keys(w.byState)) {
+		rows = append(rows, []any{state, w.byState[state]})
+	}
+
+	for i, row := range rows {
+		cell := fmt.Sprintf("A%d", i+1)
+		if err := w.file.SetSheetRow(xlsxSummarySheet, cell, &row); err != nil {
+			return err
+		}
+	}
+	return w.file.SetColWidth(xlsxSummarySheet, "A", "A", 24)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+// openXLSX opens an exported workbook for inspection
+func openXLSX(t *testing.T, output []byte) *excelize.File {
+	file, err := excelize.OpenReader(bytes.NewReader(output))
+	require.NoError(t, err, "Output should be a valid workbook")
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+func TestXLSXExportWriter(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	file := openXLSX(t, writeAll(t, formatXLSX, exportOptions{Location: berlin}, testRecords(t)))
+
+	assert.Equal(t, []string{xlsxDomainSheet, xlsxSummarySheet}, file.GetSheetList())
+
+	rows, err := file.GetRows(xlsxDomainSheet)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Domain", "Order Date", "Reg Date", "Close Date"},
+		{"example.com", "2023-01-01", "2023-01-02"},
+		{"müller.de", "2022-01-01", "2022-01-02", "2024-01-01"},
+	}, rows, "Dates should be shown as calendar days in the export time zone")
+
+	// date cells hold Excel serial numbers, not text
+	for _, cell := range []string{"B2", "C2", "B3", "C3", "D3"} {
+		cellType, err := file.GetCellType(xlsxDomainSheet, cell)
+		require.NoError(t, err)
+		assert.NotEqual(t, excelize.CellTypeSharedString, cellType, "cell %s", cell)
+		assert.NotEqual(t, excelize.CellTypeInlineString, cellType, "cell %s", cell)
+	}
+	raw, err := file.GetCellValue(xlsxDomainSheet, "D3", excelize.Options{RawCellValue: true})
+	require.NoError(t, err)
+	assert.Equal(t, "45292", raw, "2024-01-01 is serial number 45292")
+
+	panes, err := file.GetPanes(xlsxDomainSheet)
+	require.NoError(t, err)
+	assert.True(t, panes.Freeze, "Header row should be frozen")
+	assert.Equal(t, 1, panes.YSplit)
+
+	names := file.GetDefinedName()
+	require.Len(t, names, 1, "AutoFilter should be defined")
+	assert.Equal(t, "_xlnm._FilterDatabase", names[0].Name)
+	assert.Equal(t, "'Domains'!$A$1:$D$3", names[0].RefersTo)
+}
+
+func TestXLSXExportWriter_Summary(t *testing.T) {
+	records := testRecords(t)
+	extra := Domain{Name: "example.de", OrderStatus: "active", OrderDateTime: "2024-05-01T10:00:00Z"}
+	dates, errs := extra.parseDates()
+	require.Empty(t, errs)
+	records = append(records, &exportRecord{Domain: extra, Dates: dates})
+
+	file := openXLSX(t, writeAll(t, formatXLSX, exportOptions{}, records))
+
+	rows, err := file.GetRows(xlsxSummarySheet)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Domains", "3"},
+		{"Without Close Date", "2"},
+		{"With Close Date", "1"},
+		nil,
+		{"TLD", "Domains"},
+		{"com", "1"},
+		{"de", "2"},
+		nil,
+		{"Order Status", "Domains"},
+		{"active", "2"},
+		{"deleted", "1"},
+	}, rows)
+}
+
+func TestXLSXExportWriter_Empty(t *testing.T) {
+	file := openXLSX(t, writeAll(t, formatXLSX, exportOptions{}, nil))
+
+	rows, err := file.GetRows(xlsxDomainSheet)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"Domain", "Order Date", "Reg Date", "Close Date"}}, rows)
+
+	total, err := file.GetCellValue(xlsxSummarySheet, "B1")
+	require.NoError(t, err)
+	assert.Equal(t, "0", total)
+}
+
+func TestXLSXExportWriter_Columns(t *testing.T) {
+	cutoff, err := parseCutoffDate("2024-01-31", time.UTC)
+	require.NoError(t, err)
+	columns, err := parseColumns("order_status=Status,delete_date,days_in_portfolio")
+	require.NoError(t, err)
+
+	file := openXLSX(t, writeAll(t, formatXLSX, exportOptions{CutoffDate: cutoff, Columns: columns}, testRecords(t)))
+
+	rows, err := file.GetRows(xlsxDomainSheet)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Status", "Close Date", "Days in Portfolio"},
+		{"active", "", "394"},
+		{"deleted", "2023-12-31", "728"},
+	}, rows)
+
+	cellType, err := file.GetCellType(xlsxDomainSheet, "C2")
+	require.NoError(t, err)
+	assert.NotEqual(t, excelize.CellTypeSharedString, cellType, "Days should be a number cell")
+
+	names := file.GetDefinedName()
+	require.Len(t, names, 1)
+	assert.Equal(t, "'Domains'!$A$1:$C$3", names[0].RefersTo, "AutoFilter should span the selected columns")
+}